@@ -0,0 +1,240 @@
+package twowaysql
+
+import (
+	"strings"
+)
+
+// Analysis is a result of the static analysis of a 2WaySQL query by Analyze.
+type Analysis struct {
+	// Params lists top level parameters that the query refers to in the order of appearance.
+	// Loop variables of FOR are resolved to their collections.
+	Params []string `json:"params"`
+	// Binds lists bind values (/*name*/) and raw substitutions (/*$name*/).
+	Binds []BindInfo `json:"binds,omitempty"`
+	// Conditions lists conditions of IF/ELIF.
+	Conditions []ConditionInfo `json:"conditions,omitempty"`
+	// Blocks is the nesting structure of IF/ELIF/ELSE and FOR.
+	Blocks []*Block `json:"blocks,omitempty"`
+}
+
+// Position is a position in the query. Line and Column are 1-based.
+type Position struct {
+	Offset int `json:"offset"`
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// BindInfo is a bind value or a raw substitution in the query.
+type BindInfo struct {
+	// Name is the name written in the comment like "item.name".
+	Name string `json:"name"`
+	// Param is the top level parameter like "items" for "item.name" in /* FOR item IN items */.
+	Param string `json:"param"`
+	// Raw is true for /*$name*/.
+	Raw bool `json:"raw,omitempty"`
+	// Columns is the column list of /*name(column1, column2)*/.
+	Columns []string `json:"columns,omitempty"`
+	Pos     Position `json:"pos"`
+}
+
+// ConditionInfo is a condition of IF/ELIF in the query.
+type ConditionInfo struct {
+	Condition string `json:"condition"`
+	// Identifiers lists parameters used in the condition like "user.name".
+	// It is empty if the condition engine is not ExprEngine.
+	Identifiers []string `json:"identifiers,omitempty"`
+	// Params lists top level parameters of Identifiers.
+	Params []string `json:"params,omitempty"`
+	Pos    Position `json:"pos"`
+}
+
+// Block is IF, ELIF, ELSE or FOR block. ELIF and ELSE are siblings of the IF block.
+type Block struct {
+	// Kind is "IF", "ELIF", "ELSE" or "FOR".
+	Kind string `json:"kind"`
+	// Condition is the condition of IF/ELIF or the collection of FOR.
+	Condition string `json:"condition,omitempty"`
+	// Variable is the loop variable of FOR.
+	Variable string   `json:"variable,omitempty"`
+	Pos      Position `json:"pos"`
+	Children []*Block `json:"children,omitempty"`
+}
+
+// Analyze parses a 2WaySQL query and returns the parameters, the conditions and the structure without evaluating it.
+// Options like WithConditionEngine and WithSource are used as Compile, but allowed values of raw substitutions are not checked.
+func Analyze(query string, opts ...Option) (*Analysis, error) {
+	o := newOptions(opts)
+
+	tokens, err := tokenize(query)
+	if err != nil {
+		return nil, o.locate(query, err)
+	}
+
+	tree, err := ast(tokens)
+	if err != nil {
+		return nil, o.locate(query, err)
+	}
+
+	if err := tree.compileConditions(o.engine); err != nil {
+		return nil, o.locate(query, err)
+	}
+
+	a := &analyzer{
+		query:   query,
+		line:    o.line,
+		result:  &Analysis{},
+		visited: make(map[string]bool),
+	}
+	a.result.Blocks = a.walk(tree, map[string]string{})
+	return a.result, nil
+}
+
+type analyzer struct {
+	query   string
+	line    int
+	result  *Analysis
+	visited map[string]bool
+}
+
+func (a *analyzer) position(offset int) Position {
+	line, column, _ := position(a.query, offset, a.line)
+	return Position{Offset: offset, Line: line, Column: column}
+}
+
+// param returns the top level parameter of name and adds it to Params.
+// loops maps loop variables to the top level parameters of their collections
+func (a *analyzer) param(name string, loops map[string]string) string {
+	root, _, _ := strings.Cut(name, ".")
+	if p, ok := loops[root]; ok {
+		return p
+	}
+	if !a.visited[root] {
+		a.visited[root] = true
+		a.result.Params = append(a.result.Params, root)
+	}
+	return root
+}
+
+// walk follows the tree like genInner and returns blocks at the level
+func (a *analyzer) walk(node *tree, loops map[string]string) []*Block {
+	var blocks []*Block
+	for node != nil {
+		switch node.Kind {
+		case ndBind, ndRaw:
+			info := BindInfo{
+				Name:  node.Token.value,
+				Param: a.param(node.Token.value, loops),
+				Raw:   node.Kind == ndRaw,
+				Pos:   a.position(node.Token.pos),
+			}
+			if node.Token.columns != "" {
+				info.Columns = strings.Split(node.Token.columns, ",")
+			}
+			a.result.Binds = append(a.result.Binds, info)
+			node = node.Left
+		case ndIf, ndElif:
+			info := ConditionInfo{
+				Condition: node.Token.condition,
+				Pos:       a.position(node.Token.pos),
+			}
+			if c, ok := node.Condition.(*exprCondition); ok {
+				info.Identifiers = exprIdentifiers(c.node, nil)
+				for _, id := range info.Identifiers {
+					info.Params = appendUnique(info.Params, a.param(id, loops))
+				}
+			}
+			a.result.Conditions = append(a.result.Conditions, info)
+			blocks = append(blocks, &Block{
+				Kind:      node.Token.kind.String(),
+				Condition: node.Token.condition,
+				Pos:       info.Pos,
+				Children:  a.walk(node.Left, loops),
+			})
+			node = node.Right
+		case ndElse:
+			blocks = append(blocks, &Block{
+				Kind:     node.Token.kind.String(),
+				Pos:      a.position(node.Token.pos),
+				Children: a.walk(node.Left, loops),
+			})
+			node = node.Right
+		case ndFor:
+			block := &Block{
+				Kind:      node.Token.kind.String(),
+				Condition: node.Token.condition,
+				Variable:  node.Token.value,
+				Pos:       a.position(node.Token.pos),
+			}
+			collection := a.param(node.Token.condition, loops)
+			inner := make(map[string]string, len(loops)+1)
+			for k, v := range loops {
+				inner[k] = v
+			}
+			inner[node.Token.value] = collection
+			block.Children = a.walk(node.Left, inner)
+			if node.Right.Kind == ndSeparator {
+				block.Children = append(block.Children, a.walk(node.Right.Left, inner)...)
+			}
+			blocks = append(blocks, block)
+			node = endOf(node).Left
+		case ndSQLStmt, ndEnd:
+			node = node.Left
+		default:
+			return blocks
+		}
+	}
+	return blocks
+}
+
+// exprIdentifiers returns parameters used in the expression like "user.name"
+func exprIdentifiers(n exprNode, dest []string) []string {
+	switch n := n.(type) {
+	case identNode:
+		return appendUnique(dest, n.name)
+	case memberNode:
+		if path, ok := memberPath(n); ok {
+			return appendUnique(dest, path)
+		}
+		return exprIdentifiers(n.x, dest)
+	case indexNode:
+		return exprIdentifiers(n.index, exprIdentifiers(n.x, dest))
+	case lenNode:
+		return exprIdentifiers(n.x, dest)
+	case notNode:
+		return exprIdentifiers(n.x, dest)
+	case negNode:
+		return exprIdentifiers(n.x, dest)
+	case arrayNode:
+		for _, e := range n.elems {
+			dest = exprIdentifiers(e, dest)
+		}
+		return dest
+	case logicalNode:
+		return exprIdentifiers(n.r, exprIdentifiers(n.l, dest))
+	case binaryNode:
+		return exprIdentifiers(n.r, exprIdentifiers(n.l, dest))
+	}
+	return dest
+}
+
+// memberPath returns "a.b.c" for a.b.c
+func memberPath(n exprNode) (string, bool) {
+	switch n := n.(type) {
+	case identNode:
+		return n.name, true
+	case memberNode:
+		if path, ok := memberPath(n.x); ok {
+			return path + "." + n.name, true
+		}
+	}
+	return "", false
+}
+
+func appendUnique(dest []string, s string) []string {
+	for _, d := range dest {
+		if d == s {
+			return dest
+		}
+	}
+	return append(dest, s)
+}
@@ -0,0 +1,61 @@
+package twowaysql
+
+import (
+	"errors"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestAnalyze(t *testing.T) {
+	query := "SELECT * FROM person\n" +
+		"WHERE employee_no < /*maxEmpNo*/1000\n" +
+		"/* IF deptNo > 0 && user.name != null */\n" +
+		"  AND dept_no = /*deptNo*/1\n" +
+		"/* ELIF len(depts) > 0 */\n" +
+		"  AND dept_no IN /*depts*/(1, 2)\n" +
+		"/* ELSE */\n" +
+		"  /* FOR item IN filters */\n" +
+		"    /* IF item.name */first_name = /*item.name*/'x'/* END */\n" +
+		"  /* SEPARATOR OR */\n" +
+		"  /* END */\n" +
+		"/* END */\n" +
+		"ORDER BY /*$sortColumn*/employee_no"
+
+	got, err := Analyze(query)
+	assert.NilError(t, err)
+
+	assert.DeepEqual(t, []string{"maxEmpNo", "deptNo", "user", "depts", "filters", "sortColumn"}, got.Params)
+	assert.DeepEqual(t, []BindInfo{
+		{Name: "maxEmpNo", Param: "maxEmpNo", Pos: Position{Offset: 41, Line: 2, Column: 21}},
+		{Name: "deptNo", Param: "deptNo", Pos: Position{Offset: 115, Line: 4, Column: 17}},
+		{Name: "depts", Param: "depts", Pos: Position{Offset: 170, Line: 6, Column: 18}},
+		{Name: "item.name", Param: "filters", Pos: Position{Offset: 260, Line: 9, Column: 36}},
+		{Name: "sortColumn", Param: "sortColumn", Raw: true, Pos: Position{Offset: 338, Line: 13, Column: 10}},
+	}, got.Binds)
+	assert.DeepEqual(t, []ConditionInfo{
+		{Condition: "deptNo > 0 && user.name != null", Identifiers: []string{"deptNo", "user.name"}, Params: []string{"deptNo", "user"}, Pos: Position{Offset: 58, Line: 3, Column: 1}},
+		{Condition: "len(depts) > 0", Identifiers: []string{"depts"}, Params: []string{"depts"}, Pos: Position{Offset: 127, Line: 5, Column: 1}},
+		{Condition: "item.name", Identifiers: []string{"item.name"}, Params: []string{"filters"}, Pos: Position{Offset: 229, Line: 9, Column: 5}},
+	}, got.Conditions)
+
+	assert.Equal(t, 3, len(got.Blocks))
+	assert.Equal(t, "IF", got.Blocks[0].Kind)
+	assert.Equal(t, "ELIF", got.Blocks[1].Kind)
+	assert.Equal(t, "ELSE", got.Blocks[2].Kind)
+	assert.Equal(t, 1, len(got.Blocks[2].Children))
+	loop := got.Blocks[2].Children[0]
+	assert.Equal(t, "FOR", loop.Kind)
+	assert.Equal(t, "filters", loop.Condition)
+	assert.Equal(t, "item", loop.Variable)
+	assert.Equal(t, 1, len(loop.Children))
+	assert.Equal(t, "item.name", loop.Children[0].Condition)
+}
+
+func TestAnalyze_Error(t *testing.T) {
+	_, err := Analyze("SELECT * FROM person\nWHERE /* IF deptNo */ dept_no = /*deptNo*/1", WithSource("person.sql", 1))
+	var syntaxErr *SyntaxError
+	assert.Assert(t, errors.As(err, &syntaxErr))
+	assert.Equal(t, "person.sql", syntaxErr.File)
+	assert.Equal(t, 2, syntaxErr.Line)
+}
@@ -15,14 +15,19 @@ const (
 	ndElse
 	ndEnd
 	ndEndOfProgram
+	ndFor
+	ndSeparator
+	ndRaw
 )
 
 // tree is a component of an abstract syntax tree
 type tree struct {
-	Kind  nodeKind
-	Left  *tree
-	Right *tree
-	Token *token
+	Kind      nodeKind
+	Left      *tree
+	Right     *tree
+	Token     *token
+	Condition Condition       /* for IF/ELIF */
+	Allowed   map[string]bool /* for Raw */
 }
 
 // astはトークン列から抽象構文木を生成する。
@@ -31,7 +36,9 @@ type tree struct {
 // stmt = 	SQLStmt stmt |
 //
 //		BIND	stmt |
+//		RAW	stmt |
 //	  	"IF" stmt ("ELLF" stmt)* ("ELSE" stmt)? "END" stmt |
+//	  	"FOR" stmt ("SEPARATOR" stmt)? "END" stmt |
 //		EndOfProgram
 func ast(tokens []token) (*tree, error) {
 	node, err := program(tokens)
@@ -39,6 +46,10 @@ func ast(tokens []token) (*tree, error) {
 		return nil, err
 	}
 	if node.nodeCount() != len(tokens) {
+		// stmtはELIF/ELSE/END/SEPARATORに対応するIF/FORがないと途中で止まる
+		if tok := unexpectedToken(tokens, node); tok != nil {
+			return nil, newSyntaxError(tok.pos, tok.str, fmt.Sprintf("can not parse: unexpected %s", tok.describe()))
+		}
 		return nil, errors.New("can not generate abstract syntax tree")
 	}
 
@@ -74,6 +85,17 @@ func stmt(tokens []token, index *int) (*tree, error) {
 			Token: &tokens[*index-1],
 		}
 
+		node.Left, err = stmt(tokens, index)
+		if err != nil {
+			return nil, err
+		}
+	} else if consume(tokens, index, tkRaw) {
+		// Raw stmt
+		node = &tree{
+			Kind:  ndRaw,
+			Token: &tokens[*index-1],
+		}
+
 		node.Left, err = stmt(tokens, index)
 		if err != nil {
 			return nil, err
@@ -145,11 +167,61 @@ func stmt(tokens []token, index *int) (*tree, error) {
 			if err != nil {
 				return nil, err
 			}
+		} else if tokens[*index].kind == tkEndOfProgram {
+			return nil, newSyntaxError(node.Token.pos, node.Token.str, "can not parse: not found /* END */", "ELIF", "ELSE", "END")
 		} else {
-			return nil, fmt.Errorf("can not parse: expected /* END */, but got %v", tokens[*index].kind)
+			tok := &tokens[*index]
+			return nil, newSyntaxError(tok.pos, tok.str, fmt.Sprintf("can not parse: expected /* END */, but got %s", tok.describe()), "END")
 		}
 
 		// どれも一致しなかった
+		return node, nil
+	} else if consume(tokens, index, tkFor) {
+		// "FOR" stmt ("SEPARATOR" stmt)? "END" stmt
+		node = &tree{
+			Kind:  ndFor,
+			Token: &tokens[*index-1],
+		}
+		node.Left, err = stmt(tokens, index)
+		if err != nil {
+			return nil, err
+		}
+		tmpNode := node
+
+		if consume(tokens, index, tkSeparator) {
+			// ("SEPARATOR" stmt)?
+			child := &tree{
+				Kind:  ndSeparator,
+				Token: &tokens[*index-1],
+			}
+			tmpNode.Right = child
+			tmpNode = child
+
+			child.Left, err = stmt(tokens, index)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if consume(tokens, index, tkEnd) {
+			// "END"
+			child := &tree{
+				Kind:  ndEnd,
+				Token: &tokens[*index-1],
+			}
+			tmpNode.Right = child
+
+			child.Left, err = stmt(tokens, index)
+			if err != nil {
+				return nil, err
+			}
+		} else if tokens[*index].kind == tkEndOfProgram {
+			return nil, newSyntaxError(node.Token.pos, node.Token.str, "can not parse: not found /* END */", "SEPARATOR", "END")
+		} else {
+			tok := &tokens[*index]
+			return nil, newSyntaxError(tok.pos, tok.str, fmt.Sprintf("can not parse: expected /* END */, but got %s", tok.describe()), "END")
+		}
+
 		return node, nil
 	}
 	return node, nil
@@ -189,3 +261,24 @@ func (t *tree) countInner(count *int) {
 		t.Right.countInner(count)
 	}
 }
+
+// unexpectedToken returns the first token that is not in the tree
+func unexpectedToken(tokens []token, node *tree) *token {
+	used := make(map[*token]bool, len(tokens))
+	node.markTokens(used)
+	for i := range tokens {
+		if !used[&tokens[i]] {
+			return &tokens[i]
+		}
+	}
+	return nil
+}
+
+func (t *tree) markTokens(used map[*token]bool) {
+	if t == nil {
+		return
+	}
+	used[t.Token] = true
+	t.Left.markTokens(used)
+	t.Right.markTokens(used)
+}
@@ -0,0 +1,212 @@
+package twowaysql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// maxPlaceholders is the limit of bind parameters in one statement of each driver.
+var maxPlaceholders = map[string]int{
+	"pgx":       65535,
+	"pgx/v5":    65535,
+	"postgres":  65535,
+	"mysql":     65535,
+	"sqlite":    999,
+	"sqlite3":   999,
+	"sqlserver": 2100,
+	"mssql":     2100,
+}
+
+const defaultMaxPlaceholders = 999
+
+// WithMaxPlaceholders sets the limit of bind parameters in one statement used by BulkExec.
+// By default, the limit is decided by the driver name (65535 for PostgreSQL and MySQL, 999 for SQLite and 2100 for SQL Server).
+func WithMaxPlaceholders(n int) Option {
+	return func(o *options) {
+		o.maxPlaceholders = n
+	}
+}
+
+var valuesPattern = regexp.MustCompile(`(?i)\bVALUES\s*\(`)
+
+// BulkExec inserts rows by query in a transaction.
+// query must be an INSERT statement with a single VALUES tuple like
+// `INSERT INTO persons (first_name, last_name) VALUES (/*first_name*/'Jeff', /*last_name*/'Dean')`.
+// rows takes a slice of structs or maps that are used as parameters of each row.
+// Parameters are allowed only in the VALUES tuple since the rest of the statement is shared by all rows.
+// The rows are split into multiple statements so that each statement doesn't exceed the placeholder limit of the driver.
+// It returns the total number of rows affected.
+func (t *Twowaysql) BulkExec(ctx context.Context, query string, rows interface{}) (int64, error) {
+	var affected int64
+	err := t.Transaction(ctx, func(tx *TwowaysqlTx) error {
+		var err error
+		affected, err = tx.BulkExec(ctx, query, rows)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return affected, nil
+}
+
+// BulkExec inserts rows by query within the transaction.
+// It is an equivalent implementation of Twowaysql.BulkExec
+func (t *TwowaysqlTx) BulkExec(ctx context.Context, query string, rows interface{}) (int64, error) {
+	return bulkExec(ctx, t.tx, query, rows, t.opts, t.hooks)
+}
+
+func bulkExec(ctx context.Context, tx *sqlx.Tx, query string, rows interface{}, opts []Option, hooks []Hook) (int64, error) {
+	// tuples are concatenated, so placeholders must not be numbered
+	tmpl, err := Compile(query, append(append([]Option{}, opts...), WithPlaceholder(Question))...)
+	if err != nil {
+		return 0, reportError(ctx, hooks, "BulkExec", tx.DriverName(), nil, err)
+	}
+	// errors before sending the statements are reported to hooks as well
+	fail := func(err error) (int64, error) {
+		return 0, reportError(ctx, hooks, "BulkExec", tx.DriverName(), tmpl, err)
+	}
+
+	rv := reflect.ValueOf(rows)
+	for rv.Kind() == reflect.Pointer {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return fail(fmt.Errorf("BulkExec requires slice of structs or maps, but %T", rows))
+	}
+	if rv.Len() == 0 {
+		return 0, nil
+	}
+
+	var prefix, suffix string
+	tuples := make([]string, rv.Len())
+	params := make([][]interface{}, rv.Len())
+	// redactedParams is nil if no sensitive values are bound
+	var redactedParams [][]interface{}
+	for i := 0; i < rv.Len(); i++ {
+		eval, bindParams, redacted, err := tmpl.eval(ctx, recordParams(rv.Index(i)))
+		if err != nil {
+			return fail(fmt.Errorf("row %d: %w", i, err))
+		}
+		if redacted != nil && redactedParams == nil {
+			redactedParams = make([][]interface{}, rv.Len())
+			copy(redactedParams, params)
+		}
+		if redactedParams != nil {
+			if redacted == nil {
+				redacted = bindParams
+			}
+			redactedParams[i] = redacted
+		}
+		p, tuple, s, err := splitValues(eval)
+		if err != nil {
+			return fail(err)
+		}
+		if i == 0 {
+			// the rest of the statement is sent once for all rows, so it can't have parameters of each row
+			if n := countPlaceholders(tuple); n != len(bindParams) {
+				return fail(fmt.Errorf("BulkExec supports parameters only in the VALUES tuple, but %d parameters are outside of it", len(bindParams)-n))
+			}
+			prefix, suffix = p, s
+		} else if p != prefix || s != suffix || len(bindParams) != len(params[0]) {
+			return fail(fmt.Errorf("row %d: all rows must generate the same statement", i))
+		}
+		tuples[i] = tuple
+		params[i] = bindParams
+	}
+
+	limit := tmpl.opts.maxPlaceholders
+	if limit <= 0 {
+		var ok bool
+		if limit, ok = maxPlaceholders[tx.DriverName()]; !ok {
+			limit = defaultMaxPlaceholders
+		}
+	}
+	chunkSize := len(tuples)
+	if len(params[0]) > 0 {
+		chunkSize = limit / len(params[0])
+	}
+	if chunkSize == 0 {
+		return fail(fmt.Errorf("a row has %d parameters, that exceeds the placeholder limit %d", len(params[0]), limit))
+	}
+
+	var total int64
+	for start := 0; start < len(tuples); start += chunkSize {
+		end := start + chunkSize
+		if end > len(tuples) {
+			end = len(tuples)
+		}
+		var bindParams, redacted []interface{}
+		for _, p := range params[start:end] {
+			bindParams = append(bindParams, p...)
+		}
+		if redactedParams != nil {
+			for _, p := range redactedParams[start:end] {
+				redacted = append(redacted, p...)
+			}
+		}
+		q := tx.Rebind(prefix + strings.Join(tuples[start:end], ", ") + suffix)
+		event := newQueryEvent("BulkExec", tx.DriverName(), tmpl, q, bindParams, redacted)
+		err = runHooks(ctx, hooks, event, func(ctx context.Context) error {
+			var err error
+			event.Result, err = tx.ExecContext(ctx, q, bindParams...)
+			return redactError(err, bindParams, redacted)
+		})
+		if err != nil {
+			return 0, err
+		}
+		affected, err := event.Result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		total += affected
+	}
+	return total, nil
+}
+
+// splitValues splits INSERT statement into the part before the VALUES tuple, the tuple and the rest
+func splitValues(query string) (string, string, string, error) {
+	loc := valuesPattern.FindStringIndex(query)
+	if loc == nil {
+		return "", "", "", errors.New("BulkExec requires INSERT ... VALUES (...) statement")
+	}
+	start := loc[1] - 1
+	depth := 0
+	var quote byte
+	for i := start; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+			if depth == 0 {
+				return query[:start], query[start : i+1], query[i+1:], nil
+			}
+		}
+	}
+	return "", "", "", errors.New("VALUES tuple is not closed")
+}
+
+// countPlaceholders counts ? placeholders in query except literals and comments
+func countPlaceholders(query string) int {
+	n := 0
+	for _, s := range splitLiterals(query) {
+		if s.literal || s.lineComment || strings.HasPrefix(s.str, "/*") {
+			continue
+		}
+		n += strings.Count(s.str, "?")
+	}
+	return n
+}
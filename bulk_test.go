@@ -0,0 +1,183 @@
+package twowaysql
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"gotest.tools/v3/assert"
+	_ "modernc.org/sqlite"
+)
+
+func openSQLite(t *testing.T) *sqlx.DB {
+	t.Helper()
+	db, err := sqlx.Open("sqlite", ":memory:")
+	assert.NilError(t, err)
+	// each connection of in-memory database has its own database
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() {
+		db.Close()
+	})
+	_, err = db.Exec(`CREATE TABLE persons (employee_no INTEGER PRIMARY KEY, first_name TEXT NOT NULL, last_name TEXT)`)
+	assert.NilError(t, err)
+	return db
+}
+
+func TestBulkExec(t *testing.T) {
+	type Person struct {
+		EmpNo     int    `twowaysql:"employee_no"`
+		FirstName string `twowaysql:"first_name"`
+		LastName  string `twowaysql:"last_name"`
+	}
+
+	tests := []struct {
+		name string
+		opts []Option
+		rows int
+	}{
+		{
+			name: "driver limit",
+			rows: 2500,
+		},
+		{
+			name: "option limit",
+			opts: []Option{WithMaxPlaceholders(10)},
+			rows: 25,
+		},
+		{
+			name: "dollar placeholder",
+			opts: []Option{WithPlaceholder(Dollar)},
+			rows: 10,
+		},
+		{
+			name: "no rows",
+			rows: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openSQLite(t)
+			tw := New(db, tt.opts...)
+
+			people := make([]Person, tt.rows)
+			for i := range people {
+				people[i] = Person{EmpNo: i + 1, FirstName: fmt.Sprintf("first%d", i), LastName: fmt.Sprintf("last%d", i)}
+			}
+
+			affected, err := tw.BulkExec(context.Background(), `INSERT INTO persons (employee_no, first_name, last_name) VALUES (/*employee_no*/1, /*first_name*/'Jeff', /*last_name*/'Dean')`, people)
+			assert.NilError(t, err)
+			assert.Equal(t, int64(tt.rows), affected)
+
+			var count int
+			assert.NilError(t, db.Get(&count, `SELECT COUNT(*) FROM persons`))
+			assert.Equal(t, tt.rows, count)
+			if tt.rows > 0 {
+				var last Person
+				assert.NilError(t, db.QueryRowx(`SELECT employee_no, first_name, last_name FROM persons ORDER BY employee_no DESC LIMIT 1`).Scan(&last.EmpNo, &last.FirstName, &last.LastName))
+				assert.Equal(t, people[tt.rows-1], last)
+			}
+		})
+	}
+}
+
+func TestBulkExec_Map(t *testing.T) {
+	db := openSQLite(t)
+	tw := New(db)
+
+	rows := []map[string]interface{}{
+		{"employee_no": 1, "first_name": "Jeff", "last_name": nil},
+		{"employee_no": 2, "first_name": "Dan", "last_name": "Conner"},
+	}
+	affected, err := tw.BulkExec(context.Background(), `INSERT INTO persons (employee_no, first_name, last_name) VALUES (/*employee_no*/1, /*first_name*/'Jeff', /*last_name*/'Dean') ON CONFLICT DO NOTHING`, rows)
+	assert.NilError(t, err)
+	assert.Equal(t, int64(2), affected)
+}
+
+func TestBulkExec_Interface(t *testing.T) {
+	type Person struct {
+		EmpNo     int    `twowaysql:"employee_no"`
+		FirstName string `twowaysql:"first_name"`
+	}
+	db := openSQLite(t)
+	tw := New(db)
+
+	rows := []interface{}{Person{EmpNo: 1, FirstName: "Jeff"}, &Person{EmpNo: 2, FirstName: "Dan"}}
+	affected, err := tw.BulkExec(context.Background(), `INSERT INTO persons (employee_no, first_name) VALUES (/*employee_no*/1, /*first_name*/'Jeff')`, rows)
+	assert.NilError(t, err)
+	assert.Equal(t, int64(2), affected)
+}
+
+func TestBulkExec_Rollback(t *testing.T) {
+	db := openSQLite(t)
+	tw := New(db, WithMaxPlaceholders(4))
+
+	rows := []map[string]interface{}{
+		{"employee_no": 1, "first_name": "Jeff"},
+		{"employee_no": 2, "first_name": "Dan"},
+		{"employee_no": 3, "first_name": nil},
+	}
+	_, err := tw.BulkExec(context.Background(), `INSERT INTO persons (employee_no, first_name) VALUES (/*employee_no*/1, /*first_name*/'Jeff')`, rows)
+	assert.ErrorContains(t, err, "NOT NULL constraint failed")
+
+	var count int
+	assert.NilError(t, db.Get(&count, `SELECT COUNT(*) FROM persons`))
+	assert.Equal(t, 0, count)
+}
+
+func TestBulkExec_Error(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		rows      interface{}
+		opts      []Option
+		wantError string
+	}{
+		{
+			name:      "not slice",
+			query:     `INSERT INTO persons (employee_no) VALUES (/*employee_no*/1)`,
+			rows:      map[string]interface{}{"employee_no": 1},
+			wantError: "BulkExec requires slice of structs or maps, but map[string]interface {}",
+		},
+		{
+			name:      "not insert values",
+			query:     `INSERT INTO persons (employee_no) SELECT /*employee_no*/1`,
+			rows:      []map[string]interface{}{{"employee_no": 1}},
+			wantError: "BulkExec requires INSERT ... VALUES (...) statement",
+		},
+		{
+			name:      "different statements",
+			query:     `INSERT INTO persons (employee_no, first_name) VALUES (/*employee_no*/1, /* IF first_name */ /*first_name*/'Jeff' /* ELSE */ 'unknown' /* END */)`,
+			rows:      []map[string]interface{}{{"employee_no": 1, "first_name": "Jeff"}, {"employee_no": 2, "first_name": ""}},
+			wantError: "row 1: all rows must generate the same statement",
+		},
+		{
+			name:      "missing parameter",
+			query:     `INSERT INTO persons (employee_no) VALUES (/*employee_no*/1)`,
+			rows:      []map[string]interface{}{{"employee_no": 1}, {}},
+			wantError: "row 1: no parameter that matches the bind value: employee_no",
+		},
+		{
+			name:      "parameters outside of VALUES",
+			query:     `INSERT INTO persons (employee_no, first_name) VALUES (/*employee_no*/1, 'Jeff') ON CONFLICT (employee_no) DO UPDATE SET first_name = /*first_name*/'Jeff'`,
+			rows:      []map[string]interface{}{{"employee_no": 1, "first_name": "Jeff"}, {"employee_no": 2, "first_name": "Dan"}},
+			wantError: "BulkExec supports parameters only in the VALUES tuple, but 1 parameters are outside of it",
+		},
+		{
+			name:      "too many parameters",
+			query:     `INSERT INTO persons (employee_no, first_name) VALUES (/*employee_no*/1, /*first_name*/'Jeff')`,
+			rows:      []map[string]interface{}{{"employee_no": 1, "first_name": "Jeff"}},
+			opts:      []Option{WithMaxPlaceholders(1)},
+			wantError: "a row has 2 parameters, that exceeds the placeholder limit 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tw := New(openSQLite(t), tt.opts...)
+			_, err := tw.BulkExec(context.Background(), tt.query, tt.rows)
+			assert.Error(t, err, tt.wantError)
+		})
+	}
+}
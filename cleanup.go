@@ -0,0 +1,209 @@
+package twowaysql
+
+import (
+	"errors"
+	"strings"
+)
+
+// WithCleanup removes dangling keywords left by dropped blocks like <where> and <set> of MyBatis.
+//
+//   - WHERE and HAVING without conditions are removed
+//   - AND/OR just after WHERE/HAVING or "(" or before the end of the clause are removed
+//   - commas before FROM, WHERE, ")" or the end of the clause and just after SET are removed
+//
+// So the template doesn't need "WHERE 1=1".
+// If the WHERE clause of UPDATE or DELETE becomes empty, evaluation fails with ErrEmptyWhere
+// instead of removing it, because the statement would change all rows.
+func WithCleanup() Option {
+	return func(o *options) {
+		o.cleanup = true
+	}
+}
+
+// clauseEnds are words that end a WHERE/HAVING clause
+var clauseEnds = map[string]bool{
+	")":         true,
+	";":         true,
+	"GROUP":     true,
+	"ORDER":     true,
+	"HAVING":    true,
+	"LIMIT":     true,
+	"OFFSET":    true,
+	"FETCH":     true,
+	"FOR":       true,
+	"WINDOW":    true,
+	"UNION":     true,
+	"EXCEPT":    true,
+	"INTERSECT": true,
+	"RETURNING": true,
+}
+
+// columnFollowers are words that follow a column name, not a clause keyword, like "offset = 1" or "window IS NULL"
+var columnFollowers = map[string]bool{
+	"IS": true, "IN": true, "NOT": true, "LIKE": true, "ILIKE": true, "BETWEEN": true,
+	"=": true, "<": true, ">": true, "!": true, "+": true, "-": true, "*": true, "/": true, "%": true, "|": true, "&": true, "^": true,
+}
+
+// ErrEmptyWhere is returned by WithCleanup when all conditions of the WHERE clause of UPDATE or DELETE are dropped.
+var ErrEmptyWhere = errors.New("all conditions of WHERE clause of UPDATE or DELETE are dropped")
+
+// lexeme is a word, a punctuation, a literal or a bind value in the generated tokens
+type lexeme struct {
+	token      int
+	start, end int
+	// word is an upper case word or a punctuation. it is empty for literals and bind values
+	word string
+}
+
+// cleanup removes dangling WHERE/HAVING, AND/OR and commas from the generated tokens.
+// The removed words are replaced with mark.
+func cleanup(tokens []token, mark string) ([]token, error) {
+	lexemes := lexemes(tokens)
+	verbs := statementVerbs(lexemes)
+	removed := make([]bool, len(lexemes))
+	nextIndex := func(i int) int {
+		for j := i + 1; j < len(lexemes); j++ {
+			if !removed[j] {
+				return j
+			}
+		}
+		return -1
+	}
+	// next returns the next word that is not removed and false at the end of the query
+	next := func(i int) (string, bool) {
+		if j := nextIndex(i); j != -1 {
+			return lexemes[j].word, true
+		}
+		return "", false
+	}
+	// endsClause returns true if the next word ends the clause.
+	// A keyword followed by an operator like "offset = 1", FROM, "," or ")" like "id, limit FROM" is a column name.
+	endsClause := func(i int) bool {
+		j := nextIndex(i)
+		if j == -1 {
+			return true
+		}
+		if !clauseEnds[lexemes[j].word] {
+			return false
+		}
+		if lexemes[j].word == ")" || lexemes[j].word == ";" {
+			return true
+		}
+		w, ok := next(j)
+		return ok && !columnFollowers[w] && w != "FROM" && w != "," && w != ")"
+	}
+	for i, l := range lexemes {
+		if removed[i] {
+			continue
+		}
+		switch l.word {
+		case "(":
+			for j := nextIndex(i); j != -1 && (lexemes[j].word == "AND" || lexemes[j].word == "OR"); j = nextIndex(i) {
+				removed[j] = true
+			}
+		case "WHERE", "HAVING":
+			for j := nextIndex(i); j != -1 && (lexemes[j].word == "AND" || lexemes[j].word == "OR"); j = nextIndex(i) {
+				removed[j] = true
+			}
+			if endsClause(i) {
+				if l.word == "WHERE" && (verbs[i] == "UPDATE" || verbs[i] == "DELETE") {
+					return nil, ErrEmptyWhere
+				}
+				removed[i] = true
+			}
+		case "AND", "OR":
+			if w, _ := next(i); endsClause(i) || w == "AND" || w == "OR" {
+				removed[i] = true
+			}
+		case ",":
+			if w, _ := next(i); endsClause(i) || w == "FROM" || w == "WHERE" || w == "," {
+				removed[i] = true
+			}
+		case "SET":
+			for j := nextIndex(i); j != -1 && lexemes[j].word == ","; j = nextIndex(i) {
+				removed[j] = true
+			}
+		}
+	}
+
+	result := make([]token, len(tokens))
+	copy(result, tokens)
+	for i := len(lexemes) - 1; i >= 0; i-- {
+		if !removed[i] {
+			continue
+		}
+		l := lexemes[i]
+		str := result[l.token].str
+		result[l.token].str = str[:l.start] + mark + str[l.end:]
+	}
+	return result, nil
+}
+
+// statementVerbs returns SELECT, INSERT, UPDATE, DELETE or MERGE of the statement that each lexeme belongs to.
+// Subqueries in parentheses have their own verbs.
+func statementVerbs(lexemes []lexeme) []string {
+	result := make([]string, len(lexemes))
+	stack := []string{""}
+	for i, l := range lexemes {
+		switch l.word {
+		case "(":
+			stack = append(stack, "")
+		case ")":
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+		case ";":
+			stack = []string{""}
+		case "SELECT", "INSERT", "UPDATE", "DELETE", "MERGE":
+			if stack[len(stack)-1] == "" {
+				stack[len(stack)-1] = l.word
+			}
+		}
+		result[i] = stack[len(stack)-1]
+	}
+	return result
+}
+
+// lexemes splits SQL statements of the generated tokens into words.
+// Comments and whitespace are skipped and a bind value is a lexeme.
+func lexemes(tokens []token) []lexeme {
+	var result []lexeme
+	for i, tok := range tokens {
+		if tok.kind != tkSQLStmt {
+			result = append(result, lexeme{token: i, start: 0, end: len(tok.str)})
+			continue
+		}
+		offset := 0
+		for _, s := range splitLiterals(tok.str) {
+			switch {
+			case s.lineComment || strings.HasPrefix(s.str, "/*"):
+			case s.literal:
+				result = append(result, lexeme{token: i, start: offset, end: offset + len(s.str)})
+			default:
+				for j := 0; j < len(s.str); {
+					c := s.str[j]
+					switch {
+					case isSpace(c) || c == dropMark[0]:
+						j++
+					case isWordByte(c):
+						k := j
+						for k < len(s.str) && isWordByte(s.str[k]) {
+							k++
+						}
+						result = append(result, lexeme{token: i, start: offset + j, end: offset + k, word: strings.ToUpper(s.str[j:k])})
+						j = k
+					default:
+						result = append(result, lexeme{token: i, start: offset + j, end: offset + j + 1, word: s.str[j : j+1]})
+						j++
+					}
+				}
+			}
+			offset += len(s.str)
+		}
+	}
+	return result
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c == '.' || c == '$' || c >= 0x80 || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
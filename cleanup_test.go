@@ -0,0 +1,155 @@
+package twowaysql
+
+import (
+	"errors"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestEval_Cleanup(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		params map[string]interface{}
+		want   string
+	}{
+		{
+			name:   "empty WHERE",
+			input:  `SELECT * FROM person WHERE /* IF deptNo */ dept_no = /*deptNo*/1 /* END */ /* IF email */ AND email = /*email*/'x' /* END */ ORDER BY id`,
+			params: map[string]interface{}{"deptNo": 0, "email": ""},
+			want:   `SELECT * FROM person ORDER BY id`,
+		},
+		{
+			name:   "empty WHERE at the end",
+			input:  `SELECT * FROM person WHERE /* IF deptNo */ dept_no = /*deptNo*/1 /* END */`,
+			params: map[string]interface{}{"deptNo": 0},
+			want:   `SELECT * FROM person`,
+		},
+		{
+			name:   "leading AND",
+			input:  `SELECT * FROM person WHERE /* IF deptNo */ dept_no = /*deptNo*/1 /* END */ /* IF email */ AND email = /*email*/'x' /* END */`,
+			params: map[string]interface{}{"deptNo": 0, "email": "jeff@example.com"},
+			want:   `SELECT * FROM person WHERE email = ?/*email*/`,
+		},
+		{
+			name:   "trailing OR",
+			input:  `SELECT * FROM person WHERE name = /*name*/'x' OR /* IF email */ email = /*email*/'x' /* END */ GROUP BY dept_no HAVING /* IF minCount */ count(*) > /*minCount*/1 /* END */`,
+			params: map[string]interface{}{"name": "Jeff", "email": "", "minCount": 0},
+			want:   `SELECT * FROM person WHERE name = ?/*name*/ GROUP BY dept_no`,
+		},
+		{
+			name:   "subquery",
+			input:  `SELECT * FROM person WHERE dept_no IN (SELECT dept_no FROM dept WHERE /* IF area */ area = /*area*/'x' /* END */) AND name = /*name*/'x'`,
+			params: map[string]interface{}{"area": "", "name": "Jeff"},
+			want:   `SELECT * FROM person WHERE dept_no IN (SELECT dept_no FROM dept ) AND name = ?/*name*/`,
+		},
+		{
+			name:   "trailing comma in SET",
+			input:  `UPDATE person SET /* IF name */ name = /*name*/'x', /* END */ /* IF email */ email = /*email*/'x', /* END */ WHERE id = /*id*/1`,
+			params: map[string]interface{}{"name": "Jeff", "email": "", "id": 1},
+			want:   `UPDATE person SET name = ?/*name*/ WHERE id = ?/*id*/`,
+		},
+		{
+			name:   "leading comma in SET",
+			input:  `UPDATE person SET /* IF name */ name = /*name*/'x' /* END */ /* IF email */ , email = /*email*/'x' /* END */ WHERE id = /*id*/1`,
+			params: map[string]interface{}{"name": "", "email": "jeff@example.com", "id": 1},
+			want:   `UPDATE person SET email = ?/*email*/ WHERE id = ?/*id*/`,
+		},
+		{
+			name:   "trailing comma before FROM",
+			input:  `SELECT id, /* IF withEmail */ email, /* END */ FROM person`,
+			params: map[string]interface{}{"withEmail": false},
+			want:   `SELECT id FROM person`,
+		},
+		{
+			name:   "literals and BETWEEN are kept",
+			input:  `SELECT 'WHERE', "AND" FROM person WHERE age BETWEEN /*min*/1 AND /*max*/2 AND note = 'a, ' -- WHERE AND`,
+			params: map[string]interface{}{"min": 1, "max": 2},
+			want:   `SELECT 'WHERE', "AND" FROM person WHERE age BETWEEN ?/*min*/ AND ?/*max*/ AND note = 'a, ' -- WHERE AND`,
+		},
+		{
+			name:   "keywords as column names",
+			input:  `SELECT * FROM person WHERE /* IF offset */ offset = /*offset*/1 /* END */ /* IF window */ AND window IS NOT NULL /* END */ /* IF fetch */ AND fetch > /*fetch*/1 /* END */ LIMIT 10`,
+			params: map[string]interface{}{"offset": 0, "window": true, "fetch": 0},
+			want:   `SELECT * FROM person WHERE window IS NOT NULL LIMIT 10`,
+		},
+		{
+			name:   "keyword as a column name after WHERE",
+			input:  `SELECT * FROM person WHERE offset = /*offset*/1 /* IF fetch */ AND fetch = /*fetch*/1 /* END */ OFFSET 10`,
+			params: map[string]interface{}{"offset": 1, "fetch": 0},
+			want:   `SELECT * FROM person WHERE offset = ?/*offset*/ OFFSET 10`,
+		},
+		{
+			name:   "keywords as selected columns",
+			input:  `SELECT id, offset, limit FROM t WHERE /* IF false */ a = 1 /* END */`,
+			params: map[string]interface{}{},
+			want:   `SELECT id, offset, limit FROM t`,
+		},
+		{
+			name:   "keyword as the last selected column in subquery",
+			input:  `SELECT id, (SELECT id, fetch) FROM t`,
+			params: map[string]interface{}{},
+			want:   `SELECT id, (SELECT id, fetch) FROM t`,
+		},
+		{
+			name:   "leading OR after parenthesis",
+			input:  `SELECT * FROM t WHERE ( /*IF false*/a=1/*END*/ /*IF true*/ OR b = 2 /*END*/ ) AND (/*IF true*/ AND c = 3 /*END*/)`,
+			params: map[string]interface{}{},
+			want:   `SELECT * FROM t WHERE ( b = 2 ) AND ( c = 3 )`,
+		},
+		{
+			name:   "empty WHERE in subquery of UPDATE",
+			input:  `UPDATE person SET dept_no = (SELECT max(dept_no) FROM dept WHERE /* IF area */ area = /*area*/'x' /* END */) WHERE id = /*id*/1`,
+			params: map[string]interface{}{"area": "", "id": 1},
+			want:   `UPDATE person SET dept_no = (SELECT max(dept_no) FROM dept ) WHERE id = ?/*id*/`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := Eval(tt.input, tt.params, WithCleanup())
+			assert.NilError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestEval_CleanupLines(t *testing.T) {
+	query := "SELECT *\n" +
+		"FROM person\n" +
+		"WHERE\n" +
+		"  /* IF deptNo */\n" +
+		"  dept_no = /*deptNo*/1\n" +
+		"  /* END */\n" +
+		"ORDER BY id"
+	got, _, err := Eval(query, map[string]interface{}{"deptNo": 0}, WithCleanup(), WithFormat(FormatLines))
+	assert.NilError(t, err)
+	assert.Equal(t, "SELECT *\nFROM person\nORDER BY id", got)
+}
+
+func TestEval_CleanupEmptyWhere(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{
+			name:  "UPDATE",
+			input: `UPDATE person SET name = /*name*/'x' WHERE /* IF id */ id = /*id*/1 /* END */`,
+		},
+		{
+			name:  "DELETE",
+			input: `DELETE FROM person WHERE /* IF id */ id = /*id*/1 /* END */ /* IF email */ AND email = /*email*/'x' /* END */ RETURNING id`,
+		},
+		{
+			name:  "DELETE with CTE",
+			input: `WITH old AS (SELECT id FROM person) DELETE FROM person WHERE /* IF id */ id = /*id*/1 /* END */`,
+		},
+	}
+	params := map[string]interface{}{"name": "Jeff", "id": 0, "email": ""}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := Eval(tt.input, params, WithCleanup())
+			assert.Assert(t, errors.Is(err, ErrEmptyWhere))
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package cli
 
 import (
+	"context"
 	"fmt"
 	"os"
 
@@ -11,7 +12,14 @@ import (
 	"github.com/future-architect/go-twowaysql"
 )
 
-func eval(srcPath string, params []string) error {
+var sqlFormats = map[string]twowaysql.Format{
+	"collapse": twowaysql.FormatCollapseSpaces,
+	"preserve": twowaysql.FormatPreserve,
+	"lines":    twowaysql.FormatLines,
+	"compact":  twowaysql.FormatCompact,
+}
+
+func eval(srcPath string, params []string, sqlFormat string) error {
 	stat, _ := os.Stdin.Stat()
 	var finalParams map[string]any
 	var err error
@@ -24,12 +32,18 @@ func eval(srcPath string, params []string) error {
 		return err
 	}
 
-	srcSql, err := readSql(srcPath)
+	srcSql, finalParams, opts, err := readSql(srcPath, finalParams)
 	if err != nil {
 		return err
 	}
 
-	convertedSrc, sqlParams, err := twowaysql.Eval(srcSql, finalParams)
+	opts = append(opts, twowaysql.WithFormat(sqlFormats[sqlFormat]))
+	tmpl, err := twowaysql.Compile(srcSql, opts...)
+	if err != nil {
+		return err
+	}
+	// values of sensitive parameters are not shown
+	convertedSrc, sqlParams, err := tmpl.EvalRedacted(context.Background(), finalParams)
 	if err != nil {
 		return err
 	}
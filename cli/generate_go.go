@@ -0,0 +1,346 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/future-architect/go-twowaysql"
+	"github.com/jmoiron/sqlx"
+)
+
+var goFileTemplate = template.Must(template.New("go").Parse(`// Code generated by twowaysql generate go. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+{{- range .Imports}}
+	{{.}}
+{{- end}}
+)
+
+//go:embed {{.SQLFile}}
+var {{.Var}}SQL string
+
+var {{.Var}}Template = twowaysql.MustCompile({{.Var}}SQL{{range .Options}}, {{.}}{{end}})
+
+// {{.Name}}Params is parameters of {{.Title}}.
+type {{.Name}}Params struct {
+{{- range .Fields}}
+{{- if .Description}}
+	// {{.Description}}
+{{- end}}
+	{{.Name}} {{.Type}} ` + "`" + `twowaysql:"{{.Tag}}"` + "`" + `
+{{- end}}
+}
+{{if .Results}}
+// {{.Name}}Result is a row of {{.Title}}.
+type {{.Name}}Result struct {
+{{- range .Results}}
+	{{.Name}} {{.Type}} ` + "`" + `db:"{{.Tag}}"` + "`" + `{{if .Description}} // {{.Description}}{{end}}
+{{- end}}
+}
+
+// {{.Name}} runs {{.Title}}.
+func {{.Name}}(ctx context.Context, db twowaysql.Querier, params {{.Name}}Params) ([]{{.Name}}Result, error) {
+	var result []{{.Name}}Result
+	err := db.SelectTemplate(ctx, &result, {{.Var}}Template, &params)
+	return result, err
+}
+{{- else if .Select}}
+// {{.Name}} runs {{.Title}}.
+// dest takes a pointer to a slice of a struct. The struct tag format must be ` + "`" + `db:"tag_name"` + "`" + `.
+func {{.Name}}(ctx context.Context, db twowaysql.Querier, dest any, params {{.Name}}Params) error {
+	return db.SelectTemplate(ctx, dest, {{.Var}}Template, &params)
+}
+{{- else}}
+// {{.Name}} runs {{.Title}}.
+func {{.Name}}(ctx context.Context, db twowaysql.Querier, params {{.Name}}Params) (sql.Result, error) {
+	return db.ExecTemplate(ctx, {{.Var}}Template, &params)
+}
+{{- end}}
+`))
+
+type goFile struct {
+	Package string
+	Imports []string
+	SQLFile string
+	Var     string
+	Name    string
+	Title   string
+	Options []string
+	Fields  []goField
+	Results []goField
+	Select  bool
+}
+
+type goField struct {
+	Name        string
+	Type        string
+	Tag         string
+	Description string
+}
+
+// generateGo writes a Go package that has a params struct, a function and embedded SQL for each Markdown file.
+// If withResult is true, result structs of queries are generated from column types of the database.
+func generateGo(driver, dbSrc string, filesOrDirs []string, outDir, pkg string, withResult bool) error {
+	var db *sqlx.DB
+	var schema tableSchema
+	if withResult {
+		var err error
+		db, err = sqlx.Open(driver, dbSrc)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		schema = loadTableSchema(context.Background(), db)
+	}
+	if pkg == "" {
+		abs, err := filepath.Abs(outDir)
+		if err != nil {
+			return err
+		}
+		pkg = goPackageName(filepath.Base(abs))
+	}
+	var files []*goFile
+	sqls := make(map[string]string)
+	found := make(map[string]string)
+	for _, f := range findFiles(filesOrDirs) {
+		doc, err := twowaysql.ParseMarkdownFile(f)
+		if err != nil {
+			return fmt.Errorf("%s: %w", f, err)
+		}
+		if _, err := twowaysql.Compile(doc.SQL, append(doc.Options(), twowaysql.WithSource(f, doc.SQLLine))...); err != nil {
+			return err
+		}
+		base := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(f), ".md"), ".sql")
+		file := newGoFile(pkg, base, doc)
+		if db != nil && file.Select {
+			file.Results, err = inferResult(context.Background(), db, schema, doc)
+			if err != nil {
+				return fmt.Errorf("%s: can't infer result: %w", f, err)
+			}
+		}
+		file.setImports()
+		if prev, ok := found[file.Name]; ok {
+			return fmt.Errorf("%s and %s generate the same name %s", prev, f, file.Name)
+		}
+		found[file.Name] = f
+		files = append(files, file)
+		sqls[base] = doc.SQL
+	}
+
+	if err := os.MkdirAll(outDir, 0o755); err != nil {
+		return err
+	}
+	for _, file := range files {
+		src, err := file.generate()
+		if err != nil {
+			return err
+		}
+		base := strings.TrimSuffix(file.SQLFile, ".sql")
+		if err := os.WriteFile(filepath.Join(outDir, base+".sql"), []byte(strings.TrimSpace(sqls[base])+"\n"), 0o644); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(outDir, base+".go"), src, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newGoFile(pkg, base string, doc *twowaysql.Document) *goFile {
+	name := goIdentifier(base)
+	title := doc.Title
+	if title == "" {
+		title = base
+	}
+	file := &goFile{
+		Package: pkg,
+		SQLFile: base + ".sql",
+		Var:     strings.ToLower(name[:1]) + name[1:],
+		Name:    name,
+		Title:   title,
+		Select:  isQuery(doc.SQL),
+	}
+	conditions := conditionParams(doc)
+	for _, p := range doc.Params {
+		typ := goType(p.Type)
+		// a zero value like time.Time{} is truthy in conditions, so nil is needed to drop the block
+		if conditions[p.Name] && !strings.HasPrefix(typ, "[]") && typ != "any" {
+			typ = "*" + typ
+		}
+		tag := p.Name
+		if p.Sensitive {
+			tag += ",sensitive"
+		}
+		file.Fields = append(file.Fields, goField{
+			Name:        goIdentifier(p.Name),
+			Type:        typ,
+			Tag:         tag,
+			Description: strings.TrimSpace(p.Description),
+		})
+		if len(p.AllowedValues) > 0 {
+			args := []string{fmt.Sprintf("%q", p.Name)}
+			for _, v := range p.AllowedValues {
+				args = append(args, fmt.Sprintf("%q", v))
+			}
+			file.Options = append(file.Options, "twowaysql.WithAllowedValues("+strings.Join(args, ", ")+")")
+		}
+	}
+	return file
+}
+
+// conditionParams returns parameters used in conditions of IF/ELIF
+func conditionParams(doc *twowaysql.Document) map[string]bool {
+	result := make(map[string]bool)
+	analysis, err := twowaysql.Analyze(doc.SQL, doc.Options()...)
+	if err != nil {
+		return result
+	}
+	for _, c := range analysis.Conditions {
+		for _, p := range c.Params {
+			result[p] = true
+		}
+	}
+	return result
+}
+
+// setImports sets packages used by the fields and the function
+func (f *goFile) setImports() {
+	imports := make(map[string]bool)
+	if !f.Select {
+		imports["database/sql"] = true
+	}
+	for _, fields := range [][]goField{f.Fields, f.Results} {
+		for _, field := range fields {
+			if strings.Contains(field.Type, "sql.Null") {
+				imports["database/sql"] = true
+			}
+			if strings.Contains(field.Type, "time.") {
+				imports["time"] = true
+			}
+		}
+	}
+	f.Imports = []string{`"context"`}
+	if imports["database/sql"] {
+		f.Imports = append(f.Imports, `"database/sql"`)
+	}
+	f.Imports = append(f.Imports, `_ "embed"`)
+	if imports["time"] {
+		f.Imports = append(f.Imports, `"time"`)
+	}
+	f.Imports = append(f.Imports, "", `"github.com/future-architect/go-twowaysql"`)
+}
+
+// generate returns gofmt-ed source
+func (f *goFile) generate() ([]byte, error) {
+	var b bytes.Buffer
+	if err := goFileTemplate.Execute(&b, f); err != nil {
+		return nil, err
+	}
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("generated code of %s is invalid: %w", f.SQLFile, err)
+	}
+	return src, nil
+}
+
+// goType returns the Go type of the parameter that is the same as the result of ParamType.Convert except arrays
+func goType(t twowaysql.ParamType) string {
+	if t.IsArray() {
+		return "[]" + goType(t.Elem())
+	}
+	switch t {
+	case twowaysql.BoolType:
+		return "bool"
+	case twowaysql.ByteType:
+		return "uint8"
+	case twowaysql.FloatType:
+		return "float64"
+	case twowaysql.IntType:
+		return "int64"
+	case twowaysql.TextType:
+		return "string"
+	case twowaysql.TimestampType, twowaysql.DateType:
+		return "time.Time"
+	case twowaysql.DecimalType:
+		return "twowaysql.Decimal"
+	case twowaysql.JSONType:
+		return "twowaysql.JSON"
+	default:
+		return "any"
+	}
+}
+
+var (
+	queryKeywords  = regexp.MustCompile(`(?i)^(SELECT|WITH|VALUES|SHOW|EXPLAIN|TABLE)\b`)
+	returning      = regexp.MustCompile(`(?i)\bRETURNING\b`)
+	leadingComment = regexp.MustCompile(`^(\s+|--[^\n]*|/\*(?s:.*?)\*/)`)
+	quoted         = regexp.MustCompile(`'(?:[^']|'')*'|"(?:[^"]|"")*"`)
+)
+
+// isQuery returns true if the SQL returns rows
+func isQuery(sql string) bool {
+	sql = stripLeadingComments(sql)
+	return queryKeywords.MatchString(sql) || returning.MatchString(quoted.ReplaceAllString(sql, ""))
+}
+
+// stripLeadingComments removes spaces and comments at the beginning of the SQL
+func stripLeadingComments(sql string) string {
+	for {
+		loc := leadingComment.FindStringIndex(sql)
+		if loc == nil {
+			return sql
+		}
+		sql = sql[loc[1]:]
+	}
+}
+
+// initialisms are upper-cased in Go identifiers
+var initialisms = map[string]bool{
+	"API": true, "DB": true, "HTML": true, "HTTP": true, "ID": true, "IP": true,
+	"JSON": true, "SQL": true, "URI": true, "URL": true, "UUID": true, "XML": true,
+}
+
+// goIdentifier converts names like "first_name" and "select-person" to exported identifiers like "FirstName"
+func goIdentifier(name string) string {
+	var b strings.Builder
+	for _, word := range strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if upper := strings.ToUpper(word); initialisms[upper] {
+			b.WriteString(upper)
+			continue
+		}
+		runes := []rune(word)
+		b.WriteRune(unicode.ToUpper(runes[0]))
+		b.WriteString(string(runes[1:]))
+	}
+	result := b.String()
+	if result == "" || !unicode.IsLetter([]rune(result)[0]) {
+		result = "Q" + result
+	}
+	return result
+}
+
+// goPackageName converts a directory name to a package name
+func goPackageName(dir string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(dir) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	if b.Len() == 0 || !unicode.IsLetter([]rune(b.String())[0]) {
+		return "queries"
+	}
+	return b.String()
+}
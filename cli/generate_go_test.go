@@ -0,0 +1,172 @@
+package cli
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/future-architect/go-twowaysql"
+	"github.com/jmoiron/sqlx"
+	"github.com/shibukawa/acquire-go"
+	"gotest.tools/v3/assert"
+
+	_ "modernc.org/sqlite"
+)
+
+func Test_generateGo(t *testing.T) {
+	src := acquire.MustAcquire(acquire.Dir, "testdata/generate/markdown")[0]
+	golden := acquire.MustAcquire(acquire.Dir, "testdata/generate/queries")[0]
+	out := filepath.Join(t.TempDir(), "queries")
+
+	err := generateGo("", "", []string{src}, out, "", false)
+	assert.NilError(t, err)
+
+	want, err := os.ReadDir(golden)
+	assert.NilError(t, err)
+	got, err := os.ReadDir(out)
+	assert.NilError(t, err)
+	assert.Equal(t, len(want), len(got))
+	for _, e := range want {
+		wantSrc, err := os.ReadFile(filepath.Join(golden, e.Name()))
+		assert.NilError(t, err)
+		gotSrc, err := os.ReadFile(filepath.Join(out, e.Name()))
+		assert.NilError(t, err)
+		assert.Equal(t, string(wantSrc), string(gotSrc), e.Name())
+	}
+}
+
+func Test_generateGo_result(t *testing.T) {
+	src := acquire.MustAcquire(acquire.Dir, "testdata/generate/markdown")[0]
+	golden := acquire.MustAcquire(acquire.File, "testdata/generate/result/search_persons.go")[0]
+	dbSrc := filepath.Join(t.TempDir(), "test.db")
+	out := filepath.Join(t.TempDir(), "queries")
+
+	db := sqlx.MustOpen("sqlite", dbSrc)
+	db.MustExec(`CREATE TABLE persons (
+		employee_no INTEGER PRIMARY KEY,
+		dept_no INTEGER NOT NULL,
+		first_name VARCHAR(100) NOT NULL,
+		last_name VARCHAR(100),
+		email TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL
+	)`)
+	assert.NilError(t, db.Close())
+
+	err := generateGo("sqlite", dbSrc, []string{src}, out, "queries", true)
+	assert.NilError(t, err)
+
+	want, err := os.ReadFile(golden)
+	assert.NilError(t, err)
+	got, err := os.ReadFile(filepath.Join(out, "search_persons.go"))
+	assert.NilError(t, err)
+	assert.Equal(t, string(want), string(got))
+
+	// queries without rows are not changed
+	want, err = os.ReadFile(acquire.MustAcquire(acquire.File, "testdata/generate/queries/insert-person.go")[0])
+	assert.NilError(t, err)
+	got, err = os.ReadFile(filepath.Join(out, "insert-person.go"))
+	assert.NilError(t, err)
+	assert.Equal(t, string(want), string(got))
+}
+
+func Test_goColumnType(t *testing.T) {
+	db := sqlx.MustOpen("sqlite", ":memory:")
+	defer db.Close()
+	db.MustExec(`CREATE TABLE items (id INTEGER, name VARCHAR(10), price NUMERIC, created_at DATETIME, data BLOB)`)
+	rows, err := db.Query(`SELECT id, name, price, created_at, data, count(*) AS cnt FROM items`)
+	assert.NilError(t, err)
+	defer rows.Close()
+	columnTypes, err := rows.ColumnTypes()
+	assert.NilError(t, err)
+	var got []string
+	for _, ct := range columnTypes {
+		got = append(got, goColumnType(ct))
+	}
+	assert.DeepEqual(t, []string{"int64", "string", "string", "time.Time", "[]byte", "int64"}, got)
+}
+
+func Test_inferResult(t *testing.T) {
+	ctx := context.Background()
+	db := sqlx.MustOpen("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	defer db.Close()
+	db.MustExec(`CREATE TABLE persons (employee_no INTEGER PRIMARY KEY, first_name TEXT NOT NULL, last_name TEXT)`)
+	db.MustExec(`CREATE TABLE depts (dept_no INTEGER NOT NULL, first_name TEXT)`)
+	schema := loadTableSchema(ctx, db)
+
+	tests := []struct {
+		name string
+		sql  string
+		want []goField
+	}{
+		{
+			name: "select",
+			sql:  "SELECT first_name, last_name, count(*) AS cnt FROM persons WHERE employee_no = /*no*/1 -- comment",
+			want: []goField{
+				{Name: "FirstName", Type: "string", Tag: "first_name"},
+				{Name: "LastName", Type: "sql.Null[string]", Tag: "last_name"},
+				{Name: "Cnt", Type: "any", Tag: "cnt", Description: "type is unknown"},
+			},
+		},
+		{
+			name: "columns of the same name in joined tables",
+			sql:  "SELECT p.first_name, d.dept_no, 'x' AS note FROM persons p JOIN depts d ON p.first_name = d.first_name;",
+			want: []goField{
+				{Name: "FirstName", Type: "sql.Null[string]", Tag: "first_name"},
+				{Name: "DeptNo", Type: "int64", Tag: "dept_no"},
+				{Name: "Note", Type: "any", Tag: "note", Description: "type is unknown"},
+			},
+		},
+		{
+			name: "DML is not run",
+			sql:  "INSERT INTO persons (employee_no, first_name) VALUES (/*no*/1, 'Jeff') RETURNING employee_no",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := twowaysql.ParseMarkdownString("# Query\n\n```sql\n" + tt.sql + "\n```\n\n## Parameters\n\n| Name | Type |\n|------|------|\n| no   | int  |\n")
+			assert.NilError(t, err)
+			got, err := inferResult(ctx, db, schema, doc)
+			assert.NilError(t, err)
+			assert.DeepEqual(t, tt.want, got)
+		})
+	}
+	var count int
+	assert.NilError(t, db.Get(&count, "SELECT count(*) FROM persons"))
+	assert.Equal(t, 0, count)
+}
+
+func Test_goIdentifier(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "first_name", want: "FirstName"},
+		{name: "select-person", want: "SelectPerson"},
+		{name: "user_id", want: "UserID"},
+		{name: "01_select", want: "Q01Select"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, goIdentifier(tt.name))
+		})
+	}
+}
+
+func Test_isQuery(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want bool
+	}{
+		{sql: "SELECT * FROM persons", want: true},
+		{sql: "-- comment\n/* IF true */with t AS (SELECT 1) SELECT * FROM t/* END */", want: true},
+		{sql: "INSERT INTO persons (id) VALUES (1) RETURNING id", want: true},
+		{sql: "UPDATE persons SET name = 'returning'", want: false},
+		{sql: "DELETE FROM persons", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sql, func(t *testing.T) {
+			assert.Equal(t, tt.want, isQuery(tt.sql))
+		})
+	}
+}
@@ -0,0 +1,250 @@
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/future-architect/go-twowaysql"
+	"github.com/jmoiron/sqlx"
+)
+
+// selectKeywords are statements that can be wrapped by a subquery to get columns without running them
+var selectKeywords = regexp.MustCompile(`(?i)^(SELECT|WITH|VALUES|TABLE)\b`)
+
+// inferResult returns fields of the result struct from the column types of the query.
+// The query is wrapped as "SELECT * FROM (query) WHERE 1=0" in a transaction that is rolled back,
+// so no rows are read or changed. It returns nil for statements that can't be wrapped like INSERT ... RETURNING,
+// and the function takes dest instead.
+// Params are taken from the first test case and zero values of the types.
+func inferResult(ctx context.Context, db *sqlx.DB, schema tableSchema, doc *twowaysql.Document) ([]goField, error) {
+	if !selectKeywords.MatchString(stripLeadingComments(doc.SQL)) {
+		return nil, nil
+	}
+	params, err := sampleParams(doc)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := twowaysql.Compile(doc.SQL, doc.Options()...)
+	if err != nil {
+		return nil, err
+	}
+	query, binds, err := tmpl.EvalContext(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	query = strings.TrimRight(strings.TrimSpace(query), ";")
+	// the newline ends a line comment at the end of the query
+	wrapped := fmt.Sprintf("SELECT * FROM (%s\n) twowaysql_result WHERE 1=0", query)
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	rows, err := tx.QueryContext(ctx, db.Rebind(wrapped), binds...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+
+	tables := schema.referencedTables(query)
+	var result []goField
+	names := make(map[string]int)
+	for _, ct := range columnTypes {
+		name := goIdentifier(ct.Name())
+		names[name]++
+		if names[name] > 1 {
+			name += strconv.Itoa(names[name])
+		}
+		typ := goColumnType(ct)
+		nullable, known := ct.Nullable()
+		// modernc.org/sqlite reports all columns as nullable
+		if !known || strings.HasPrefix(db.DriverName(), "sqlite") {
+			nullable, known = schema.nullable(tables, ct.Name())
+		}
+		var description string
+		switch {
+		case typ == "any":
+			// expressions of SQLite have no types without rows
+			description = "type is unknown"
+		case !known:
+			nullable = true
+			description = "nullability is unknown"
+		}
+		if nullable && typ != "[]byte" && typ != "any" {
+			typ = fmt.Sprintf("sql.Null[%s]", typ)
+		}
+		result = append(result, goField{
+			Name:        name,
+			Type:        typ,
+			Tag:         ct.Name(),
+			Description: description,
+		})
+	}
+	return result, nil
+}
+
+// tableSchema maps lower-cased table names to NOT NULL constraints of their lower-cased column names
+type tableSchema map[string]map[string]bool
+
+// loadTableSchema reads NOT NULL constraints of columns. It is for drivers that don't report nullability
+// of result columns like SQLite and pgx. It returns nil if the database doesn't support the query.
+func loadTableSchema(ctx context.Context, db *sqlx.DB) tableSchema {
+	query := `SELECT table_name, column_name, CASE WHEN is_nullable = 'NO' THEN 1 ELSE 0 END FROM information_schema.columns
+		WHERE table_schema NOT IN ('pg_catalog', 'information_schema', 'mysql', 'performance_schema', 'sys')`
+	if strings.HasPrefix(db.DriverName(), "sqlite") {
+		query = `SELECT m.name, p.name, p."notnull" FROM sqlite_master m JOIN pragma_table_info(m.name) p WHERE m.type = 'table'`
+	}
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+	result := make(tableSchema)
+	for rows.Next() {
+		var table, column string
+		var notNull bool
+		if err := rows.Scan(&table, &column, &notNull); err != nil {
+			return nil
+		}
+		table = strings.ToLower(table)
+		if result[table] == nil {
+			result[table] = make(map[string]bool)
+		}
+		result[table][strings.ToLower(column)] = notNull
+	}
+	if rows.Err() != nil {
+		return nil
+	}
+	return result
+}
+
+var sqlWord = regexp.MustCompile(`[\p{L}\p{N}_$]+`)
+
+// referencedTables returns tables whose names appear in the query
+func (s tableSchema) referencedTables(query string) []string {
+	var result []string
+	found := make(map[string]bool)
+	for _, word := range sqlWord.FindAllString(strings.ToLower(query), -1) {
+		if _, ok := s[word]; ok && !found[word] {
+			found[word] = true
+			result = append(result, word)
+		}
+	}
+	return result
+}
+
+// nullable guesses nullability of the result column from the columns of the same name in the tables.
+// The column is NOT NULL only if all of them are NOT NULL. known is false if no tables have the column.
+// Columns of outer joins may be null even if they are NOT NULL.
+func (s tableSchema) nullable(tables []string, column string) (nullable, known bool) {
+	column = strings.ToLower(column)
+	for _, table := range tables {
+		notNull, ok := s[table][column]
+		if !ok {
+			continue
+		}
+		known = true
+		if !notNull {
+			return true, true
+		}
+	}
+	return false, known
+}
+
+// sampleParams returns params of the first test case. Other parameters are zero values of their types.
+func sampleParams(doc *twowaysql.Document) (map[string]any, error) {
+	params := make(map[string]any)
+	for _, p := range doc.Params {
+		if len(p.AllowedValues) > 0 {
+			params[p.Name] = p.AllowedValues[0]
+		} else {
+			params[p.Name] = zeroValue(p.Type)
+		}
+	}
+	if len(doc.TestCases) == 0 {
+		return params, nil
+	}
+	testParams := make(map[string]any, len(doc.TestCases[0].Params))
+	for k, v := range doc.TestCases[0].Params {
+		testParams[k] = v
+	}
+	converted, err := doc.ConvertParams(testParams)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range converted {
+		params[k] = v
+	}
+	return params, nil
+}
+
+// zeroValue returns a value of the type. Arrays have an element not to make an empty IN clause.
+func zeroValue(t twowaysql.ParamType) any {
+	if t.IsArray() {
+		return []any{zeroValue(t.Elem())}
+	}
+	switch t {
+	case twowaysql.BoolType:
+		return false
+	case twowaysql.ByteType:
+		return uint8(0)
+	case twowaysql.FloatType:
+		return float64(0)
+	case twowaysql.IntType:
+		return int64(0)
+	case twowaysql.TimestampType, twowaysql.DateType:
+		return time.Time{}
+	case twowaysql.DecimalType:
+		return twowaysql.Decimal("0")
+	case twowaysql.JSONType:
+		return nil
+	default:
+		return ""
+	}
+}
+
+// columnTypes maps database type names to Go types
+var columnTypes = map[string]string{
+	"INTEGER": "int64", "INT": "int64", "BIGINT": "int64", "SMALLINT": "int64", "TINYINT": "int64", "MEDIUMINT": "int64",
+	"INT2": "int64", "INT4": "int64", "INT8": "int64", "SERIAL": "int64", "BIGSERIAL": "int64",
+	"REAL": "float64", "FLOAT": "float64", "DOUBLE": "float64", "DOUBLE PRECISION": "float64", "FLOAT4": "float64", "FLOAT8": "float64",
+	"NUMERIC": "string", "DECIMAL": "string",
+	"BOOL": "bool", "BOOLEAN": "bool",
+	"TEXT": "string", "VARCHAR": "string", "CHAR": "string", "NVARCHAR": "string", "NCHAR": "string", "CLOB": "string",
+	"CHARACTER": "string", "CHARACTER VARYING": "string", "BPCHAR": "string", "NAME": "string", "UUID": "string", "JSON": "string", "JSONB": "string",
+	"DATE": "time.Time", "DATETIME": "time.Time", "TIMESTAMP": "time.Time", "TIMESTAMPTZ": "time.Time", "TIME": "time.Time", "TIMETZ": "time.Time",
+	"BLOB": "[]byte", "BYTEA": "[]byte", "BINARY": "[]byte", "VARBINARY": "[]byte",
+}
+
+var scanTypes = map[reflect.Type]string{
+	reflect.TypeOf(int64(0)):    "int64",
+	reflect.TypeOf(float64(0)):  "float64",
+	reflect.TypeOf(""):          "string",
+	reflect.TypeOf(false):       "bool",
+	reflect.TypeOf([]byte{}):    "[]byte",
+	reflect.TypeOf(time.Time{}): "time.Time",
+}
+
+// goColumnType returns the Go type of the column without nullability
+func goColumnType(ct *sql.ColumnType) string {
+	name, _, _ := strings.Cut(strings.ToUpper(strings.TrimSpace(ct.DatabaseTypeName())), "(")
+	if typ, ok := columnTypes[strings.TrimSpace(name)]; ok {
+		return typ
+	}
+	// expressions like count(*) don't have type names
+	if typ, ok := scanTypes[ct.ScanType()]; ok {
+		return typ
+	}
+	return "any"
+}
@@ -0,0 +1,39 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/fatih/color"
+	"github.com/future-architect/go-twowaysql"
+	"github.com/hashicorp/go-multierror"
+)
+
+// lint validates Markdown files and prints problems in "file:line: message" format
+func lint(w io.Writer, filesOrDirs []string) (ok bool, err error) {
+	var errs *multierror.Error
+	var count int
+	for _, f := range findFiles(filesOrDirs) {
+		doc, err := twowaysql.ParseMarkdownFile(f)
+		if err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("%s: %w", f, err))
+			continue
+		}
+		for _, e := range doc.Validate() {
+			fmt.Fprintf(w, "%s:%d: %s\n", f, e.Line, e.Msg)
+			count++
+		}
+	}
+	if errs != nil {
+		return false, errs
+	}
+	switch count {
+	case 0:
+		fmt.Fprintln(w, color.HiGreenString("ok"))
+	case 1:
+		fmt.Fprintln(w, color.HiRedString("1 problem"))
+	default:
+		fmt.Fprintln(w, color.HiRedString("%d problems", count))
+	}
+	return count == 0, nil
+}
@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/shibukawa/acquire-go"
+	"gotest.tools/v3/assert"
+)
+
+func Test_lint(t *testing.T) {
+	tests := []struct {
+		name    string
+		srcPath string
+		wantOk  bool
+		wantOut string
+	}{
+		{
+			name:    "valid",
+			srcPath: "testdata/postgres/markdown/select_person_with_param.sql.md",
+			wantOk:  true,
+			wantOut: "ok\n",
+		},
+		{
+			name:    "undeclared parameter",
+			srcPath: "testdata/postgres/markdown/select_person_notest.sql.md",
+			wantOk:  false,
+			wantOut: ":4: parameter 'first_name' is used in SQL but not declared\n1 problem\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := acquire.MustAcquire(acquire.File, tt.srcPath)
+			out := &bytes.Buffer{}
+			ok, err := lint(out, files)
+			assert.NilError(t, err)
+			assert.Equal(t, tt.wantOk, ok)
+			assert.Equal(t, tt.wantOut, strings.TrimPrefix(out.String(), files[0]))
+		})
+	}
+}
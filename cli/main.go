@@ -19,6 +19,7 @@ var (
 	runParam        = runCommand.Flag("param", "Parameter in single value or JSON (name=bob, or {\"name\": \"bob\"})").Short('p').NoEnvar().Strings()
 	runExplain      = runCommand.Flag("explain", "Run with EXPLAIN to show execution plan").Short('e').NoEnvar().Bool()
 	runRollback     = runCommand.Flag("rollback", "Run within transaction and then rollback").Short('r').NoEnvar().Bool()
+	runVerbose      = runCommand.Flag("verbose", "Show evaluated SQL and parameters (sensitive parameters are redacted)").Short('v').NoEnvar().Bool()
 	runOutputFormat = runCommand.Flag("output-format", "Result output format (default, md, json, yaml)").Short('o').Default("default").Enum("default", "md", "json", "yaml")
 
 	testCommand = app.Command("test", "Run test")
@@ -26,9 +27,13 @@ var (
 	testVerbose = testCommand.Flag("verbose", "Show more information").Short('v').Bool()
 	testQuiet   = testCommand.Flag("quiet", "Reduce information").Short('q').Bool()
 
+	lintCommand = app.Command("lint", "Check parameters of Markdown files against SQL")
+	lintFiles   = lintCommand.Arg("file/dir", "Markdown file").Required().NoEnvar().ExistingFilesOrDirs()
+
 	evalCommand = app.Command("eval", "Parse and evaluate SQL")
 	evalFile    = evalCommand.Arg("file", "SQL/Markdown file").Required().NoEnvar().ExistingFile()
 	evalParam   = evalCommand.Flag("param", "Parameter in single value or JSON (name=bob, or {\"name\": \"bob\"})").Short('p').NoEnvar().Strings()
+	evalFormat  = evalCommand.Flag("sql-format", "Whitespace format of evaluated SQL (collapse, preserve, lines, compact)").Default("collapse").Enum("collapse", "preserve", "lines", "compact")
 
 	parseCommand    = app.Command("parse", "Parse SQL/Markdown source file")
 	parseSrcFile    = parseCommand.Arg("file", "SQL file").Required().NoEnvar().ExistingFile()
@@ -38,6 +43,11 @@ var (
 	generateTemplateCommand  = generateCommand.Command("template", "Markdown template")
 	generateTemplateOutput   = generateTemplateCommand.Arg("file", "Output file").String()
 	generateTemplateLanguage = generateTemplateCommand.Flag("lang", "Language").Short('l').Enum("ja", "en")
+	generateGoCommand        = generateCommand.Command("go", "Go package from Markdown files")
+	generateGoFiles          = generateGoCommand.Arg("file/dir", "Markdown file").Required().NoEnvar().ExistingFilesOrDirs()
+	generateGoOutput         = generateGoCommand.Flag("output", "Output directory").Short('o').Required().String()
+	generateGoPackage        = generateGoCommand.Flag("package", "Package name (default: name of output directory)").String()
+	generateGoResult         = generateGoCommand.Flag("result", "Generate result structs of queries from column types by running them with --driver and --source (rolled back)").Short('r').Bool()
 
 	listCommand       = app.Command("list", "Inspection command")
 	listDriverCommand = listCommand.Command("driver", "Show supported drivers")
@@ -53,15 +63,19 @@ func Main() {
 	case listDriverCommand.FullCommand():
 		listDriver()
 	case evalCommand.FullCommand():
-		err = eval(*evalFile, *evalParam)
+		err = eval(*evalFile, *evalParam, *evalFormat)
 	case runCommand.FullCommand():
-		err = run(*driver, *source, *runFile, *runParam, *runExplain, *runRollback, *runOutputFormat, nil)
+		err = run(*driver, *source, *runFile, *runParam, *runExplain, *runRollback, *runVerbose, *runOutputFormat, nil)
 	case testCommand.FullCommand():
 		ok, err = unittest(*driver, *source, *testFiles, *testVerbose, *testQuiet)
+	case lintCommand.FullCommand():
+		ok, err = lint(os.Stdout, *lintFiles)
 	case parseCommand.FullCommand():
 		err = parseFile(*parseSrcFile, *parseDumpFormat)
 	case generateTemplateCommand.FullCommand():
 		err = generateTemplate(*generateTemplateOutput, *generateTemplateLanguage)
+	case generateGoCommand.FullCommand():
+		err = generateGo(*driver, *source, *generateGoFiles, *generateGoOutput, *generateGoPackage, *generateGoResult)
 	}
 	if err != nil {
 		color.New(color.FgHiRed).Fprintln(os.Stderr, err.Error())
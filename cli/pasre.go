@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
 
 	"github.com/alecthomas/chroma/quick"
 	"github.com/future-architect/go-twowaysql"
@@ -24,33 +23,16 @@ func parseFile(srcPath, dumpFormat string) error {
 	}
 }
 
-type sqlParams struct {
-	Params []string `json:"params"`
-}
-
 func parseSQLFile(srcPath, dumpFormat string) error {
 	src, err := os.ReadFile(srcPath)
 	if err != nil {
 		return err
 	}
-	// need more clever function...
-	params := make(map[string]any)
-	var paramNames []string
-	prefix := "no parameter that matches the bind value: "
-	for {
-		_, _, err := twowaysql.Eval(string(src), params)
-		if err == nil {
-			break
-		}
-		if strings.HasPrefix(err.Error(), prefix) {
-			param := strings.TrimPrefix(err.Error(), prefix)
-			paramNames = append(paramNames, param)
-			params[param] = ""
-		} else {
-			return fmt.Errorf("parse error: %w", err)
-		}
+	analysis, err := twowaysql.Analyze(string(src), twowaysql.WithSource(srcPath, 1))
+	if err != nil {
+		return fmt.Errorf("parse error: %w", err)
 	}
-	return dump(&sqlParams{Params: paramNames}, dumpFormat)
+	return dump(analysis, dumpFormat)
 }
 
 func parseMarkdownFile(srcPath, dumpFormat string) error {
@@ -58,6 +40,9 @@ func parseMarkdownFile(srcPath, dumpFormat string) error {
 	if err != nil {
 		return err
 	}
+	if _, err := twowaysql.Compile(doc.SQL, append(doc.Options(), twowaysql.WithSource(srcPath, doc.SQLLine))...); err != nil {
+		return fmt.Errorf("parse error: %w", err)
+	}
 	return dump(doc, dumpFormat)
 }
 
@@ -7,18 +7,23 @@ import (
 	"github.com/future-architect/go-twowaysql"
 )
 
-func readSql(srcPath string) (sql string, err error) {
+// readSql reads SQL from the file. For Markdown files, params are converted to the types in the parameter table.
+func readSql(srcPath string, params map[string]any) (sql string, convertedParams map[string]any, opts []twowaysql.Option, err error) {
 	if strings.HasSuffix(srcPath, ".md") {
 		doc, err := twowaysql.ParseMarkdownFile(srcPath)
 		if err != nil {
-			return "", err
+			return "", nil, nil, err
 		}
-		return doc.SQL, err
+		convertedParams, err = doc.ConvertParams(params)
+		if err != nil {
+			return "", nil, nil, err
+		}
+		return doc.SQL, convertedParams, append(doc.Options(), twowaysql.WithSource(srcPath, doc.SQLLine)), nil
 	}
 	src, err := os.ReadFile(srcPath)
 	if err != nil {
-		return "", err
+		return "", nil, nil, err
 	}
 
-	return string(src), nil
+	return string(src), params, []twowaysql.Option{twowaysql.WithSource(srcPath, 1)}, nil
 }
@@ -12,6 +12,7 @@ import (
 
 	"github.com/fatih/color"
 	"github.com/future-architect/go-twowaysql"
+	"github.com/goccy/go-yaml"
 	"github.com/jmoiron/sqlx"
 	"github.com/shibukawa/formatdata-go"
 	"golang.org/x/crypto/ssh/terminal"
@@ -38,7 +39,21 @@ var outputFormats = map[string]formatdata.OutputFormat{
 	"yaml":    formatdata.YAML,
 }
 
-func run(driver, dbSrc, srcFilePath string, params []string, explain, rollback bool, outputFormat string, out io.Writer) error {
+// verboseHook writes evaluated queries and bind values for run --verbose. Values of sensitive parameters are redacted.
+type verboseHook struct {
+	w io.Writer
+}
+
+func (h verboseHook) Before(ctx context.Context, event *twowaysql.QueryEvent) context.Context {
+	return ctx
+}
+
+func (h verboseHook) After(ctx context.Context, event *twowaysql.QueryEvent) {
+	args, _ := yaml.Marshal(event.Args)
+	fmt.Fprintf(h.w, "# Query (%v)\n\n%s\n\n# Parameters\n\n%s\n", event.Elapsed, event.Query, args)
+}
+
+func run(driver, dbSrc, srcFilePath string, params []string, explain, rollback, verbose bool, outputFormat string, out io.Writer) error {
 	stat, _ := os.Stdin.Stat()
 	var finalParams map[string]any
 	var err error
@@ -51,7 +66,7 @@ func run(driver, dbSrc, srcFilePath string, params []string, explain, rollback b
 		return err
 	}
 
-	srcSql, err := readSql(srcFilePath)
+	srcSql, finalParams, opts, err := readSql(srcFilePath, finalParams)
 	if err != nil {
 		return err
 	}
@@ -78,7 +93,10 @@ func run(driver, dbSrc, srcFilePath string, params []string, explain, rollback b
 	if err != nil {
 		return err
 	}
-	tws := twowaysql.New(db)
+	if verbose {
+		opts = append(opts, twowaysql.WithHooks(verboseHook{w: os.Stderr}))
+	}
+	tws := twowaysql.New(db, opts...)
 	defer tws.Close()
 
 	start := time.Now()
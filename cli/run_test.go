@@ -2,10 +2,13 @@ package cli
 
 import (
 	"bytes"
+	"context"
 	"strings"
 	"testing"
 
+	"github.com/future-architect/go-twowaysql"
 	"github.com/future-architect/go-twowaysql/private/testhelper"
+	"github.com/jmoiron/sqlx"
 	"github.com/shibukawa/acquire-go"
 	"gotest.tools/v3/assert"
 
@@ -62,7 +65,7 @@ func Test_run(t *testing.T) {
 			t.Log(tt.args.srcPath)
 			files := acquire.MustAcquire(acquire.File, tt.args.srcPath)
 			out := &bytes.Buffer{}
-			err := run(driver, dbSrc, files[0], tt.args.params, tt.args.explain, tt.args.rollback, tt.args.outputFormat, out)
+			err := run(driver, dbSrc, files[0], tt.args.params, tt.args.explain, tt.args.rollback, false, tt.args.outputFormat, out)
 			if tt.wantError != "" {
 				assert.Error(t, err, tt.wantError)
 			} else {
@@ -72,3 +75,17 @@ func Test_run(t *testing.T) {
 		})
 	}
 }
+
+func Test_verboseHook(t *testing.T) {
+	db := sqlx.MustOpen("sqlite", ":memory:")
+	defer db.Close()
+	db.MustExec(`CREATE TABLE users (name TEXT, password TEXT)`)
+
+	var out bytes.Buffer
+	tws := twowaysql.New(db, twowaysql.WithSensitive("password"), twowaysql.WithHooks(verboseHook{w: &out}))
+	_, err := tws.Exec(context.Background(), `INSERT INTO users (name, password) VALUES (/*name*/'bob', /*password*/'secret')`, map[string]any{"name": "bob", "password": "p@ssw0rd"})
+	assert.NilError(t, err)
+	assert.Assert(t, strings.Contains(out.String(), "INSERT INTO users (name, password) VALUES (?/*name*/, ?/*password*/)"), out.String())
+	assert.Assert(t, strings.Contains(out.String(), "- bob\n- \"[REDACTED]\"\n"), out.String())
+	assert.Assert(t, !strings.Contains(out.String(), "p@ssw0rd"), out.String())
+}
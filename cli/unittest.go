@@ -71,7 +71,11 @@ func (c testCallback) InsertFixtureTable(doc *twowaysql.Document, tc twowaysql.T
 
 func (c testCallback) Exec(doc *twowaysql.Document, tc twowaysql.TestCase) {
 	if c.verbose {
-		sqlParamYaml, _ := yaml.Marshal(tc.Params)
+		params := make(map[string]any, len(tc.Params))
+		for k, v := range tc.Params {
+			params[k] = v
+		}
+		sqlParamYaml, _ := yaml.Marshal(doc.RedactParams(params))
 		var buf bytes.Buffer
 		quick.Highlight(&buf, string(sqlParamYaml), "yaml", "terminal", "monokai")
 		fmt.Printf("  Exec SQL with: %s\n", strings.ReplaceAll(buf.String(), "\n", ""))
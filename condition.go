@@ -0,0 +1,149 @@
+package twowaysql
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/robertkrimen/otto"
+)
+
+// ConditionEngine compiles conditions of /* IF ... */ and /* ELIF ... */ comments.
+type ConditionEngine interface {
+	Compile(condition string) (Condition, error)
+}
+
+// Condition is a compiled condition. It must be safe for concurrent use.
+// Eval should stop and return ctx.Err() when ctx is done.
+// Values of params including nested ones are already converted by driver.Valuer and converters registered by WithConverter.
+type Condition interface {
+	Eval(ctx context.Context, params map[string]interface{}) (bool, error)
+}
+
+// EvalCanceledError is returned when evaluation of a condition is stopped by the context.
+type EvalCanceledError struct {
+	Condition string
+	Err       error
+}
+
+func (e *EvalCanceledError) Error() string {
+	return fmt.Sprintf("evaluation of condition '%s' is canceled: %v", e.Condition, e.Err)
+}
+
+func (e *EvalCanceledError) Unwrap() error {
+	return e.Err
+}
+
+// ExprEngine is the default ConditionEngine.
+//
+// It supports a small subset of JavaScript expression without loops and function definitions:
+// literals (number, string, true, false, null, undefined, [array]), parameters,
+// member access (a.b, a["b"], a[0], a.length), comparison (==, !=, ===, !==, <, <=, >, >=),
+// logical operators (&&, ||, !), membership (x in list) and len().
+type ExprEngine struct{}
+
+// Compile compiles a condition.
+func (e ExprEngine) Compile(condition string) (Condition, error) {
+	node, err := parseExpr(condition)
+	if err != nil {
+		return nil, err
+	}
+	return &exprCondition{node: node}, nil
+}
+
+type exprCondition struct {
+	node exprNode
+}
+
+func (c *exprCondition) Eval(ctx context.Context, params map[string]interface{}) (bool, error) {
+	// expression has no loops, so it is enough to check the context before evaluation
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	v, err := c.node.eval(&exprEnv{params: params})
+	if err != nil {
+		return false, err
+	}
+	return isTruthy(v), nil
+}
+
+// OttoEngine is a ConditionEngine that evaluates conditions as JavaScript by using otto.
+//
+// It is slower than ExprEngine. Infinite loops in conditions are stopped only when the context is done.
+// Use this only for compatibility with templates written for older versions.
+type OttoEngine struct{}
+
+// Compile compiles a condition.
+func (e OttoEngine) Compile(condition string) (Condition, error) {
+	// check syntax only. *otto.Script can't be shared between VMs safely.
+	if _, err := otto.New().Compile("", condition); err != nil {
+		return nil, err
+	}
+	return &ottoCondition{condition: condition}, nil
+}
+
+type ottoCondition struct {
+	condition string
+}
+
+type ottoHalt struct{}
+
+func (c *ottoCondition) Eval(ctx context.Context, params map[string]interface{}) (truth bool, err error) {
+	vm := otto.New()
+	for key, value := range params {
+		if _, ok := value.(ambiguousParam); ok {
+			continue
+		}
+		err := vm.Set(key, value)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	vm.Interrupt = make(chan func(), 1)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			vm.Interrupt <- func() {
+				panic(ottoHalt{})
+			}
+		case <-done:
+		}
+	}()
+	defer func() {
+		if p := recover(); p != nil {
+			if _, ok := p.(ottoHalt); ok {
+				truth, err = false, ctx.Err()
+				return
+			}
+			panic(p)
+		}
+	}()
+
+	result, err := vm.Run(c.condition)
+	if err != nil {
+		return false, err
+	}
+
+	return result.ToBoolean()
+}
+
+func (t *tree) compileConditions(engine ConditionEngine) error {
+	if t == nil {
+		return nil
+	}
+	if t.Kind == ndIf || t.Kind == ndElif {
+		cond, err := engine.Compile(t.Token.condition)
+		if err != nil {
+			syntaxErr := newSyntaxError(t.Token.pos, t.Token.str, err.Error())
+			syntaxErr.Err = err
+			return syntaxErr
+		}
+		t.Condition = cond
+	}
+	if err := t.Left.compileConditions(engine); err != nil {
+		return err
+	}
+	return t.Right.compileConditions(engine)
+}
@@ -0,0 +1,256 @@
+package twowaysql
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestExprEngine(t *testing.T) {
+	type Address struct {
+		City string `twowaysql:"city"`
+	}
+	type User struct {
+		Name    string   `twowaysql:"name"`
+		Address *Address `twowaysql:"address"`
+	}
+	params := map[string]interface{}{
+		"name":      "Jeff",
+		"empty":     "",
+		"age":       30,
+		"ageText":   "30",
+		"score":     int64(80),
+		"rate":      0.5,
+		"checked":   true,
+		"nil":       nil,
+		"nilSlice":  []string(nil),
+		"list":      []string{"M", "F"},
+		"ints":      []int{1, 2, 3},
+		"emptyList": []int{},
+		"dict":      map[string]int{"a": 1},
+		"user":      User{Name: "Jeff", Address: &Address{City: "Tokyo"}},
+		"noAddress": User{Name: "Dan"},
+		"time":      time.Date(2022, 7, 1, 12, 30, 30, 0, time.UTC),
+		"time2":     time.Date(2022, 7, 2, 12, 30, 30, 0, time.UTC),
+		"名前":        "Jeff",
+	}
+
+	tests := []struct {
+		condition string
+		want      bool
+	}{
+		{condition: "true", want: true},
+		{condition: "false", want: false},
+		{condition: "name", want: true},
+		{condition: `名前 == "Jeff" && user.name`, want: true},
+		{condition: "empty", want: false},
+		{condition: "nil", want: false},
+		{condition: "nilSlice", want: false},
+		{condition: "emptyList", want: true},
+		{condition: "age === 30", want: true},
+		{condition: "age == 30.0", want: true},
+		{condition: "age !== 30", want: false},
+		{condition: "score > age", want: true},
+		{condition: "score >= 80 && rate < 1", want: true},
+		{condition: "score < 80 || rate <= 0.5", want: true},
+		{condition: "!(score < 80 || rate <= 0.5)", want: false},
+		{condition: "!checked", want: false},
+		{condition: "age > -1", want: true},
+		{condition: `name == "Jeff"`, want: true},
+		{condition: `name != 'Jeff'`, want: false},
+		{condition: "name < 'K'", want: true},
+		{condition: "nil == null", want: true},
+		{condition: "nil === undefined", want: true},
+		{condition: "nilSlice != null", want: false},
+		{condition: "list != null", want: true},
+		{condition: "name != null", want: true},
+		{condition: "len(list) == 2", want: true},
+		{condition: "list.length > 1", want: true},
+		{condition: "len(nil) == 0", want: true},
+		{condition: "len(name) == 4", want: true},
+		{condition: `"M" in list`, want: true},
+		{condition: `"X" in list`, want: false},
+		{condition: "2 in ints", want: true},
+		{condition: "age in [10, 20, 30]", want: true},
+		{condition: `"a" in dict`, want: true},
+		{condition: `"ef" in name`, want: true},
+		{condition: "list[0] == 'M'", want: true},
+		{condition: "dict.a == 1", want: true},
+		{condition: "dict['b'] == null", want: true},
+		{condition: "user.address.city == 'Tokyo'", want: true},
+		{condition: "user.name == 'Jeff' && user.address != null", want: true},
+		{condition: "noAddress.address != null && noAddress.address.city == 'Tokyo'", want: false},
+		{condition: "time < time2", want: true},
+		{condition: "age > name", want: false},
+		{condition: "ageText > 20", want: true},
+		{condition: "ageText == 30", want: true},
+		{condition: "ageText != 30", want: false},
+		{condition: "ageText === 30", want: false},
+		{condition: "ageText !== 30", want: true},
+		{condition: "ageText < '4'", want: true},
+		{condition: "checked == 1", want: true},
+		{condition: "checked == '1'", want: true},
+		{condition: "empty == 0", want: true},
+		{condition: "name == 0", want: false},
+		{condition: "nil == 0", want: false},
+		{condition: "nil < 1", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.condition, func(t *testing.T) {
+			cond, err := ExprEngine{}.Compile(tt.condition)
+			assert.NilError(t, err)
+			got, err := cond.Eval(context.Background(), params)
+			assert.NilError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestExprEngine_CompileError(t *testing.T) {
+	tests := []struct {
+		condition string
+		wantError string
+	}{
+		{
+			condition: "",
+			wantError: "invalid condition '': unexpected end of condition",
+		},
+		{
+			condition: "a ==",
+			wantError: "invalid condition 'a ==': unexpected end of condition",
+		},
+		{
+			condition: "a b",
+			wantError: "invalid condition 'a b': unexpected 'b' at 2",
+		},
+		{
+			condition: "(a == 1",
+			wantError: "invalid condition '(a == 1': expected ')' at 7",
+		},
+		{
+			condition: "'abc",
+			wantError: "invalid condition ''abc': string literal is not terminated at 0",
+		},
+		{
+			condition: "a = 1",
+			wantError: "invalid condition 'a = 1': unexpected character '=' at 2",
+		},
+		{
+			condition: "while(true) {}",
+			wantError: "invalid condition 'while(true) {}': unexpected character '{' at 12",
+		},
+		{
+			condition: "名前 → 1",
+			wantError: "invalid condition '名前 → 1': unexpected character '→' at 7",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.condition, func(t *testing.T) {
+			_, err := ExprEngine{}.Compile(tt.condition)
+			assert.Error(t, err, tt.wantError)
+		})
+	}
+}
+
+func TestExprEngine_EvalError(t *testing.T) {
+	tests := []struct {
+		condition string
+		wantError string
+	}{
+		{
+			condition: "undefinedParam",
+			wantError: "'undefinedParam' is not defined",
+		},
+		{
+			condition: "nil.name",
+			wantError: "can not access 'name' of null",
+		},
+		{
+			condition: "age.name",
+			wantError: "can not access 'name' of int",
+		},
+		{
+			condition: "len(age) > 1",
+			wantError: "len() is not supported for int",
+		},
+		{
+			condition: "list > 1",
+			wantError: "'>' operator: can not compare []string with number",
+		},
+		{
+			condition: "time <= age",
+			wantError: "'<=' operator: can not compare time.Time with number",
+		},
+	}
+	params := map[string]interface{}{
+		"nil":  nil,
+		"age":  30,
+		"list": []string{"a"},
+		"time": time.Date(2022, 7, 1, 12, 30, 30, 0, time.UTC),
+	}
+	for _, tt := range tests {
+		t.Run(tt.condition, func(t *testing.T) {
+			cond, err := ExprEngine{}.Compile(tt.condition)
+			assert.NilError(t, err)
+			_, err = cond.Eval(context.Background(), params)
+			assert.Error(t, err, tt.wantError)
+		})
+	}
+}
+
+func TestExprEngine_TooDeep(t *testing.T) {
+	condition := ""
+	for i := 0; i < 100; i++ {
+		condition += "("
+	}
+	condition += "true"
+	for i := 0; i < 100; i++ {
+		condition += ")"
+	}
+	_, err := ExprEngine{}.Compile(condition)
+	assert.ErrorContains(t, err, "condition is nested too deeply")
+}
+
+func TestOttoEngine(t *testing.T) {
+	tmpl, err := Compile(`SELECT * FROM person WHERE employee_no < 1000 /* IF deptNo === 12 && [1, 2].indexOf(maxEmpNo) !== -1 */ AND dept_no = 1 /* END */`, WithConditionEngine(OttoEngine{}))
+	assert.NilError(t, err)
+	query, _, err := tmpl.Eval(&Info{DeptNo: 12, MaxEmpNo: 2})
+	assert.NilError(t, err)
+	assert.Equal(t, `SELECT * FROM person WHERE employee_no < 1000 AND dept_no = 1`, query)
+}
+
+func TestCompile_InvalidCondition(t *testing.T) {
+	_, err := Compile(`SELECT * FROM person WHERE employee_no < 1000 /* IF deptNo === */ AND dept_no = 1 /* END */`)
+	assert.Equal(t, "invalid condition 'deptNo ===': unexpected end of condition", syntaxErrorMsg(err))
+}
+
+func TestEvalContext_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, _, err := EvalContext(ctx, `SELECT * FROM person WHERE employee_no < 1000 /* IF deptNo > 10 */ AND dept_no = 1 /* END */`, &Info{DeptNo: 12})
+	var canceled *EvalCanceledError
+	assert.Check(t, errors.As(err, &canceled))
+	assert.Check(t, errors.Is(err, context.Canceled))
+	assert.Error(t, err, "evaluation of condition 'deptNo > 10' is canceled: context canceled")
+
+	// query without conditions doesn't see the context
+	query, _, err := EvalContext(ctx, `SELECT * FROM person WHERE employee_no < /*maxEmpNo*/1000`, &Info{MaxEmpNo: 3})
+	assert.NilError(t, err)
+	assert.Equal(t, `SELECT * FROM person WHERE employee_no < ?/*maxEmpNo*/`, query)
+}
+
+func TestOttoEngine_Timeout(t *testing.T) {
+	tmpl, err := Compile(`SELECT * FROM person /* IF (function() { while (true) {} })() */ WHERE dept_no = 1 /* END */`, WithConditionEngine(OttoEngine{}))
+	assert.NilError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, _, err = tmpl.EvalContext(ctx, &Info{})
+	var canceled *EvalCanceledError
+	assert.Check(t, errors.As(err, &canceled))
+	assert.Check(t, errors.Is(err, context.DeadlineExceeded))
+}
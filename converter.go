@@ -0,0 +1,233 @@
+package twowaysql
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// converters holds custom converters registered by WithConverter
+type converters map[reflect.Type]func(interface{}) (interface{}, error)
+
+// WithConverter registers a converter for parameters of type T like decimal or UUID.
+// The converted value is bound to the query and also seen by conditions of IF/ELIF.
+// It is applied to nested values (dotted paths, elements of slices and fields of struct slices) as well.
+func WithConverter[T any](convert func(T) (interface{}, error)) Option {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	return func(o *options) {
+		if o.converters == nil {
+			o.converters = make(converters)
+		}
+		o.converters[typ] = func(v interface{}) (interface{}, error) {
+			return convert(v.(T))
+		}
+	}
+}
+
+// custom returns a result of the converter for v
+func (c converters) custom(v interface{}) (interface{}, bool, error) {
+	if len(c) == 0 || v == nil {
+		return nil, false, nil
+	}
+	rv := reflect.ValueOf(v)
+	if fn, ok := c[rv.Type()]; ok {
+		result, err := fn(v)
+		return result, true, err
+	}
+	if rv.Kind() == reflect.Pointer {
+		if fn, ok := c[rv.Type().Elem()]; ok {
+			if rv.IsNil() {
+				return nil, true, nil
+			}
+			result, err := fn(rv.Elem().Interface())
+			return result, true, err
+		}
+	}
+	return nil, false, nil
+}
+
+// bindValue returns a value that is bound to the query.
+// sql.Null* types including sql.Null[T] are unwrapped. Other driver.Valuer is passed to the driver as it is.
+func (c converters) bindValue(v interface{}) (interface{}, error) {
+	if result, ok, err := c.custom(v); ok {
+		return result, err
+	}
+	if inner, ok := unwrapSQLNull(reflect.ValueOf(v)); ok {
+		return inner, nil
+	}
+	return v, nil
+}
+
+// conditionValue returns a value that is seen by conditions.
+// driver.Valuer is converted by Value(), so that invalid sql.Null* and sql.Null[T] are null.
+// Nested values are converted as well. Maps, slices, arrays and structs that have converted values are copied to
+// map[string]interface{} and []interface{}. Struct fields are copied with their tag names and field names.
+func (c converters) conditionValue(v interface{}) (interface{}, error) {
+	result, _, err := c.convertCondition(v, 0)
+	return result, err
+}
+
+// convertCondition converts v and its nested values. changed is false if v is returned as it is.
+func (c converters) convertCondition(v interface{}, depth int) (result interface{}, changed bool, err error) {
+	if result, ok, err := c.custom(v); ok {
+		return result, true, err
+	}
+	if valuer, ok := v.(driver.Valuer); ok {
+		if rv := reflect.ValueOf(v); rv.Kind() == reflect.Pointer && rv.IsNil() {
+			return nil, true, nil
+		}
+		result, err := valuer.Value()
+		return result, true, err
+	}
+	if j, ok := v.(JSON); ok {
+		var decoded interface{}
+		err := json.Unmarshal([]byte(j), &decoded)
+		return decoded, true, err
+	}
+	// a cyclic value is not converted at deeper levels
+	if depth >= maxExprDepth {
+		return v, false, nil
+	}
+	rv := reflect.ValueOf(normalizeValue(v))
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String || !c.mayConvert(rv.Type().Elem()) {
+			return v, false, nil
+		}
+		elems := make(map[string]interface{}, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			elem, elemChanged, err := c.convertCondition(iter.Value().Interface(), depth+1)
+			if err != nil {
+				return nil, false, err
+			}
+			elems[iter.Key().String()] = elem
+			changed = changed || elemChanged
+		}
+		if changed {
+			return elems, true, nil
+		}
+	case reflect.Slice, reflect.Array:
+		if !c.mayConvert(rv.Type().Elem()) {
+			return v, false, nil
+		}
+		elems := make([]interface{}, rv.Len())
+		for i := range elems {
+			elem, elemChanged, err := c.convertCondition(rv.Index(i).Interface(), depth+1)
+			if err != nil {
+				return nil, false, err
+			}
+			elems[i] = elem
+			changed = changed || elemChanged
+		}
+		if changed {
+			return elems, true, nil
+		}
+	case reflect.Struct:
+		return c.convertStruct(v, rv, depth)
+	}
+	return v, false, nil
+}
+
+// convertStruct converts fields of a struct. If any fields are converted, the struct is copied to a map
+// that has the fields by the names that memberValue accepts.
+func (c converters) convertStruct(v interface{}, rv reflect.Value, depth int) (interface{}, bool, error) {
+	rt := rv.Type()
+	fields := reflect.VisibleFields(rt)
+	values := make([]interface{}, len(fields))
+	changed := false
+	for i, f := range fields {
+		if !f.IsExported() || f.Anonymous && f.Type.Kind() == reflect.Struct {
+			continue
+		}
+		fv, err := rv.FieldByIndexErr(f.Index)
+		if err != nil {
+			// field of nil embedded pointer
+			continue
+		}
+		value, fieldChanged, err := c.convertCondition(fv.Interface(), depth+1)
+		if err != nil {
+			return nil, false, err
+		}
+		values[i] = value
+		changed = changed || fieldChanged
+	}
+	if !changed {
+		return v, false, nil
+	}
+	result := make(map[string]interface{}, len(fields))
+	for i, f := range fields {
+		if f.IsExported() && !(f.Anonymous && f.Type.Kind() == reflect.Struct) {
+			result[f.Name] = values[i]
+		}
+	}
+	// tags take precedence over field names like structField
+	for _, tag := range []string{"db", "twowaysql"} {
+		for i, f := range fields {
+			if len(f.Index) != 1 || !f.IsExported() {
+				continue
+			}
+			if name, _, _ := strings.Cut(f.Tag.Get(tag), ","); name != "" && name != "-" {
+				result[name] = values[i]
+			}
+		}
+	}
+	return result, true, nil
+}
+
+// mayConvert returns false if values of typ are never converted.
+// It is for skipping large slices and maps of plain values.
+func (c converters) mayConvert(typ reflect.Type) bool {
+	if _, ok := c[typ]; ok {
+		return true
+	}
+	switch typ.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return typ.Implements(valuerType) || reflect.PointerTo(typ).Implements(valuerType)
+	}
+	return true
+}
+
+// conditionParams converts parameters for conditions. It is the only place where the values are converted,
+// so all ConditionEngine implementations see the same values.
+func (c converters) conditionParams(params map[string]interface{}) (map[string]interface{}, error) {
+	if params == nil {
+		return nil, nil
+	}
+	result := make(map[string]interface{}, len(params))
+	for k, v := range params {
+		if _, ok := v.(ambiguousParam); ok {
+			result[k] = v
+			continue
+		}
+		converted, err := c.conditionValue(v)
+		if err != nil {
+			return nil, err
+		}
+		result[k] = converted
+	}
+	return result, nil
+}
+
+// unwrapSQLNull returns the value of sql.NullString, sql.Null[T] and so on, or nil if it is not valid
+func unwrapSQLNull(rv reflect.Value) (interface{}, bool) {
+	if !rv.IsValid() || rv.Kind() != reflect.Struct {
+		return nil, false
+	}
+	typ := rv.Type()
+	if typ.PkgPath() != "database/sql" || !strings.HasPrefix(typ.Name(), "Null") || typ.NumField() != 2 {
+		return nil, false
+	}
+	valid := rv.FieldByName("Valid")
+	if !valid.IsValid() || valid.Kind() != reflect.Bool {
+		return nil, false
+	}
+	if !valid.Bool() {
+		return nil, true
+	}
+	return rv.Field(0).Interface(), true
+}
@@ -0,0 +1,216 @@
+package twowaysql
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+
+	gocmp "github.com/google/go-cmp/cmp"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+// testDecimal is like decimal types that implement driver.Valuer as string
+type testDecimal struct {
+	unscaled int64
+	scale    int
+}
+
+func (d testDecimal) Value() (driver.Value, error) {
+	s := strconv.FormatInt(d.unscaled, 10)
+	return s[:len(s)-d.scale] + "." + s[len(s)-d.scale:], nil
+}
+
+// testUUID is like UUID types that are byte arrays
+type testUUID [16]byte
+
+type testNullUUID struct {
+	UUID  testUUID
+	Valid bool
+}
+
+func (u testNullUUID) Value() (driver.Value, error) {
+	if !u.Valid {
+		return nil, nil
+	}
+	return u.UUID[:], nil
+}
+
+func TestEval_Valuer(t *testing.T) {
+	type Params struct {
+		Name    sql.NullString   `twowaysql:"name"`
+		Age     sql.Null[int32]  `twowaysql:"age"`
+		Code    sql.NullByte     `twowaysql:"code"`
+		Email   *sql.NullString  `twowaysql:"email"`
+		ID      testNullUUID     `twowaysql:"id"`
+		Deleted sql.Null[string] `twowaysql:"deleted"`
+	}
+
+	tests := []struct {
+		name        string
+		input       string
+		inputParams interface{}
+		wantQuery   string
+		wantParams  []interface{}
+	}{
+		{
+			name:  "valid",
+			input: `SELECT * FROM person WHERE 1=1 /* IF name */ AND name = /*name*/'Jeff' /* END */ /* IF age */ AND age = /*age*/1 /* END */ /* IF code */ AND code = /*code*/1 /* END */ /* IF email */ AND email = /*email*/'x' /* END */ /* IF id */ AND id = /*id*/'x' /* END */`,
+			inputParams: &Params{
+				Name:  sql.NullString{String: "Jeff", Valid: true},
+				Age:   sql.Null[int32]{V: 30, Valid: true},
+				Code:  sql.NullByte{Byte: 1, Valid: true},
+				Email: &sql.NullString{String: "jeff@example.com", Valid: true},
+				ID:    testNullUUID{UUID: testUUID{1}, Valid: true},
+			},
+			wantQuery: `SELECT * FROM person WHERE 1=1 AND name = ?/*name*/ AND age = ?/*age*/ AND code = ?/*code*/ AND email = ?/*email*/ AND id = ?/*id*/`,
+			wantParams: []interface{}{
+				"Jeff",
+				int32(30),
+				byte(1),
+				"jeff@example.com",
+				testNullUUID{UUID: testUUID{1}, Valid: true},
+			},
+		},
+		{
+			name:        "invalid",
+			input:       `SELECT * FROM person WHERE 1=1 /* IF name */ AND name = /*name*/'Jeff' /* END */ /* IF age */ AND age = /*age*/1 /* END */ /* IF code */ AND code = /*code*/1 /* END */ /* IF email */ AND email = /*email*/'x' /* END */ /* IF id */ AND id = /*id*/'x' /* END */ /* IF deleted == null */ AND deleted IS NULL /* END */`,
+			inputParams: &Params{Email: &sql.NullString{}},
+			wantQuery:   `SELECT * FROM person WHERE 1=1 AND deleted IS NULL`,
+			wantParams:  []interface{}{},
+		},
+		{
+			name:  "valuer in map and nested path",
+			input: `SELECT * FROM person WHERE 1=1 /* IF user.id != null */ AND id = /*user.id*/'x' /* END */ /* IF age > 20 */ AND age = /*age*/1 /* END */`,
+			inputParams: map[string]interface{}{
+				"user": map[string]interface{}{"id": testNullUUID{}},
+				"age":  sql.Null[int]{V: 30, Valid: true},
+			},
+			wantQuery:  `SELECT * FROM person WHERE 1=1 AND age = ?/*age*/`,
+			wantParams: []interface{}{30},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, params, err := Eval(tt.input, tt.inputParams)
+			assert.NilError(t, err)
+			assert.Check(t, cmp.DeepEqual(tt.wantParams, params, gocmp.AllowUnexported(testNullUUID{})))
+			assert.Check(t, cmp.DeepEqual(tt.wantQuery, query))
+		})
+	}
+}
+
+func TestEval_Converter(t *testing.T) {
+	opts := []Option{
+		WithConverter(func(d testDecimal) (interface{}, error) {
+			v, err := d.Value()
+			if err != nil {
+				return nil, err
+			}
+			return strconv.ParseFloat(v.(string), 64)
+		}),
+		WithConverter(func(u testUUID) (interface{}, error) {
+			if u == (testUUID{}) {
+				return nil, nil
+			}
+			return strconv.Itoa(int(u[0])), nil
+		}),
+	}
+
+	tests := []struct {
+		name        string
+		input       string
+		inputParams interface{}
+		wantQuery   string
+		wantParams  []interface{}
+	}{
+		{
+			name:  "top level",
+			input: `SELECT * FROM item WHERE 1=1 /* IF price > 100 */ AND price = /*price*/1 /* END */ /* IF id */ AND id = /*id*/'x' /* END */`,
+			inputParams: map[string]interface{}{
+				"price": testDecimal{unscaled: 10050, scale: 2},
+				"id":    testUUID{},
+			},
+			wantQuery:  `SELECT * FROM item WHERE 1=1 AND price = ?/*price*/`,
+			wantParams: []interface{}{100.5},
+		},
+		{
+			name:  "nested",
+			input: `SELECT * FROM item WHERE 1=1 /* IF item.price > 100 */ AND price = /*item.price*/1 /* END */ AND id IN /*ids*/('x')`,
+			inputParams: map[string]interface{}{
+				"item": map[string]interface{}{"price": testDecimal{unscaled: 10050, scale: 2}},
+				"ids":  []testUUID{{1}, {2}},
+			},
+			wantQuery:  `SELECT * FROM item WHERE 1=1 AND price = ?/*item.price*/ AND id IN (?, ?)/*ids*/`,
+			wantParams: []interface{}{100.5, "1", "2"},
+		},
+		{
+			name:  "pointer",
+			input: `SELECT * FROM item WHERE 1=1 /* IF price */ AND price = /*price*/1 /* END */`,
+			inputParams: map[string]interface{}{
+				"price": (*testDecimal)(nil),
+			},
+			wantQuery:  `SELECT * FROM item WHERE 1=1`,
+			wantParams: []interface{}{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, params, err := Eval(tt.input, tt.inputParams, opts...)
+			assert.NilError(t, err)
+			assert.Check(t, cmp.DeepEqual(tt.wantParams, params))
+			assert.Check(t, cmp.DeepEqual(tt.wantQuery, query))
+		})
+	}
+}
+
+func TestEval_ConverterError(t *testing.T) {
+	errInvalid := errors.New("invalid decimal")
+	opt := WithConverter(func(d testDecimal) (interface{}, error) {
+		return nil, errInvalid
+	})
+	params := map[string]interface{}{"price": testDecimal{}}
+
+	_, _, err := Eval(`SELECT * FROM item WHERE price = /*price*/1`, params, opt)
+	assert.ErrorIs(t, err, errInvalid)
+
+	_, _, err = Eval(`SELECT * FROM item /* IF price */ WHERE price = 1 /* END */`, params, opt)
+	assert.ErrorIs(t, err, errInvalid)
+}
+
+func TestEval_ConverterEngines(t *testing.T) {
+	type Item struct {
+		Price testDecimal `twowaysql:"price"`
+		Note  sql.NullString
+	}
+	calls := 0
+	opt := WithConverter(func(d testDecimal) (interface{}, error) {
+		calls++
+		v, err := d.Value()
+		if err != nil {
+			return nil, err
+		}
+		return strconv.ParseFloat(v.(string), 64)
+	})
+	params := map[string]interface{}{
+		"price": testDecimal{unscaled: 10050, scale: 2},
+		"items": []Item{{Price: testDecimal{unscaled: 20000, scale: 2}}, {Price: testDecimal{unscaled: 5000, scale: 2}, Note: sql.NullString{String: "sale", Valid: true}}},
+	}
+	input := `SELECT * FROM item WHERE 1=1 /* IF price > 100 */ AND a /* END */ /* IF items[0].price > 100 && items[1].Note == 'sale' */ AND b /* END */ /* FOR item IN items */ /* IF item.price > 100 */ AND c /* END */ /* END */`
+
+	for _, engine := range []ConditionEngine{ExprEngine{}, OttoEngine{}} {
+		t.Run(fmt.Sprintf("%T", engine), func(t *testing.T) {
+			calls = 0
+			query, _, err := Eval(input, params, opt, WithConditionEngine(engine))
+			assert.NilError(t, err)
+			assert.Equal(t, `SELECT * FROM item WHERE 1=1 AND a AND b AND c`, query)
+			// each value is converted only once
+			assert.Equal(t, 3, calls)
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package twowaysql
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// SyntaxError is returned by Compile when a 2WaySQL query can't be parsed.
+type SyntaxError struct {
+	// File is the name of the source set by WithSource. It is empty by default.
+	File string
+	// Line and Column are 1-based position of the error. Column counts characters.
+	Line   int
+	Column int
+	// Offset is the byte offset of the error in the query.
+	Offset int
+	// Token is the text of the offending token like "/* ELSE */".
+	Token string
+	// Expected lists directives or characters expected at the position like "END" or "*/".
+	Expected []string
+	// Msg is the message without the position.
+	Msg string
+	// Snippet is the line of the query that contains the error.
+	Snippet string
+	// Err is the underlying error like an error of the condition engine.
+	Err error
+}
+
+func newSyntaxError(offset int, token, msg string, expected ...string) *SyntaxError {
+	return &SyntaxError{
+		Offset:   offset,
+		Token:    token,
+		Expected: expected,
+		Msg:      msg,
+	}
+}
+
+// Error returns the message with the position and the line of the query marked by a caret.
+//
+//	query.sql:1:47: can not parse: not found /* END */
+//	SELECT * FROM person WHERE employee_no < 1000 /* IF true */ AND dept_no = 1
+//	                                              ^
+func (e *SyntaxError) Error() string {
+	var b strings.Builder
+	if e.File != "" {
+		fmt.Fprintf(&b, "%s:%d:%d: %s", e.File, e.Line, e.Column, e.Msg)
+	} else {
+		fmt.Fprintf(&b, "line %d, column %d: %s", e.Line, e.Column, e.Msg)
+	}
+	if e.Snippet != "" {
+		b.WriteString("\n")
+		b.WriteString(e.Snippet)
+		b.WriteString("\n")
+		for i, r := range []rune(e.Snippet) {
+			if i >= e.Column-1 {
+				break
+			}
+			if r == '\t' {
+				b.WriteRune('\t')
+			} else {
+				b.WriteRune(' ')
+			}
+		}
+		b.WriteString("^")
+	}
+	return b.String()
+}
+
+func (e *SyntaxError) Unwrap() error {
+	return e.Err
+}
+
+// locate sets the line, the column and the snippet from the offset in query.
+// firstLine is the line number of the first line of query in the file.
+func (e *SyntaxError) locate(query, file string, firstLine int) {
+	if e.Offset > len(query) {
+		e.Offset = len(query)
+	}
+	e.File = file
+	e.Line, e.Column, e.Snippet = position(query, e.Offset, firstLine)
+}
+
+// position returns the line, the column and the line of query at offset
+func position(query string, offset, firstLine int) (int, int, string) {
+	if offset > len(query) {
+		offset = len(query)
+	}
+	lineStart := strings.LastIndexByte(query[:offset], '\n') + 1
+	lineEnd := strings.IndexByte(query[lineStart:], '\n')
+	if lineEnd == -1 {
+		lineEnd = len(query)
+	} else {
+		lineEnd += lineStart
+	}
+	if firstLine < 1 {
+		firstLine = 1
+	}
+	line := strings.Count(query[:lineStart], "\n") + firstLine
+	column := utf8.RuneCountInString(query[lineStart:offset]) + 1
+	return line, column, strings.TrimSuffix(query[lineStart:lineEnd], "\r")
+}
+
+// WithSource sets the file name and the line number where the query starts in the file.
+// They are used for the position of SyntaxError.
+// For a query in a Markdown file, pass Document.SQLLine as line.
+func WithSource(file string, line int) Option {
+	return func(o *options) {
+		o.file = file
+		o.line = line
+	}
+}
+
+// restOfLine returns text from offset to the end of the line
+func restOfLine(str string, offset int) string {
+	str = str[offset:]
+	if i := strings.IndexByte(str, '\n'); i != -1 {
+		str = str[:i]
+	}
+	return strings.TrimRight(str, " \t\r")
+}
@@ -0,0 +1,100 @@
+package twowaysql
+
+import (
+	"errors"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+// syntaxErrorMsg returns the message of SyntaxError without the position
+func syntaxErrorMsg(err error) string {
+	if err == nil {
+		return ""
+	}
+	var syntaxErr *SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return syntaxErr.Msg
+	}
+	return err.Error()
+}
+
+func TestSyntaxError(t *testing.T) {
+	tests := []struct {
+		name         string
+		input        string
+		opts         []Option
+		wantLine     int
+		wantColumn   int
+		wantToken    string
+		wantExpected []string
+		wantError    string
+	}{
+		{
+			name:         "no END",
+			input:        "SELECT *\nFROM person\nWHERE employee_no < 1000 /* IF true */ AND dept_no = 1",
+			wantLine:     3,
+			wantColumn:   26,
+			wantToken:    "/* IF true */",
+			wantExpected: []string{"ELIF", "ELSE", "END"},
+			wantError: "line 3, column 26: can not parse: not found /* END */\n" +
+				"WHERE employee_no < 1000 /* IF true */ AND dept_no = 1\n" +
+				"                         ^",
+		},
+		{
+			name:         "unexpected token",
+			input:        "SELECT *\nFROM person\n\t/* IF true */ AND dept_no = 1 /* ELSE */ AND id = 1 /* SEPARATOR , */",
+			wantLine:     3,
+			wantColumn:   54,
+			wantToken:    "/* SEPARATOR , */",
+			wantExpected: []string{"END"},
+			wantError: "line 3, column 54: can not parse: expected /* END */, but got /* SEPARATOR */\n" +
+				"\t/* IF true */ AND dept_no = 1 /* ELSE */ AND id = 1 /* SEPARATOR , */\n" +
+				"\t                                                    ^",
+		},
+		{
+			name:         "not closed comment with source",
+			input:        "SELECT *\nFROM person /* IF true\nWHERE dept_no = 1",
+			opts:         []Option{WithSource("person.md", 10)},
+			wantLine:     11,
+			wantColumn:   13,
+			wantToken:    "/* IF true",
+			wantExpected: []string{"*/"},
+			wantError: "person.md:11:13: Comment enclosing characters do not match\n" +
+				"FROM person /* IF true\n" +
+				"            ^",
+		},
+		{
+			name:         "not closed string",
+			input:        "SELECT * FROM person WHERE name = /*name*/'ジェフ",
+			wantLine:     1,
+			wantColumn:   43,
+			wantToken:    "'ジェフ",
+			wantExpected: []string{"'"},
+			wantError: "line 1, column 43: Enclosing characters do not match\n" +
+				"SELECT * FROM person WHERE name = /*name*/'ジェフ\n" +
+				"                                          ^",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile(tt.input, tt.opts...)
+			var syntaxErr *SyntaxError
+			assert.Assert(t, errors.As(err, &syntaxErr))
+			assert.Equal(t, tt.wantLine, syntaxErr.Line)
+			assert.Equal(t, tt.wantColumn, syntaxErr.Column)
+			assert.Equal(t, tt.wantToken, syntaxErr.Token)
+			assert.DeepEqual(t, tt.wantExpected, syntaxErr.Expected)
+			assert.Error(t, err, tt.wantError)
+		})
+	}
+}
+
+func TestSyntaxError_Condition(t *testing.T) {
+	_, err := Compile("SELECT * FROM person\nWHERE /* IF deptNo === */ dept_no = 1 /* END */")
+	var syntaxErr *SyntaxError
+	assert.Assert(t, errors.As(err, &syntaxErr))
+	assert.Equal(t, 2, syntaxErr.Line)
+	assert.Equal(t, 7, syntaxErr.Column)
+	assert.Error(t, syntaxErr.Unwrap(), "invalid condition 'deptNo ===': unexpected end of condition")
+}
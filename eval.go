@@ -2,9 +2,12 @@ package twowaysql
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
 	"time"
 	"unicode"
@@ -15,110 +18,245 @@ import (
 // Eval returns converted query and bind value.
 // inputParams takes a tagged struct. Tags must be in the form `map:"tag_name"`.
 // The return value is expected to be used to issue queries to the database
-func Eval(inputQuery string, inputParams interface{}) (string, []interface{}, error) {
-	mapParams := map[string]interface{}{}
-
-	if inputParams != nil {
-		if err := encode(mapParams, inputParams); err != nil {
-			return "", nil, err
-		}
-	} else {
-		mapParams = nil
-	}
-
-	tokens, err := tokenize(inputQuery)
-	if err != nil {
-		return "", nil, err
-	}
-
-	generatedTokens, err := parseCondition(tokens, mapParams)
+func Eval(inputQuery string, inputParams interface{}, opts ...Option) (string, []interface{}, error) {
+	tmpl, err := Compile(inputQuery, opts...)
 	if err != nil {
 		return "", nil, err
 	}
+	return tmpl.Eval(inputParams)
+}
 
-	convertedQuery, params, err := build(generatedTokens, mapParams)
+// EvalContext is like Eval but stops evaluation of conditions when ctx is done.
+// In that case, it returns *EvalCanceledError.
+func EvalContext(ctx context.Context, inputQuery string, inputParams interface{}, opts ...Option) (string, []interface{}, error) {
+	tmpl, err := Compile(inputQuery, opts...)
 	if err != nil {
 		return "", nil, err
 	}
-
-	return arrangeWhiteSpace(convertedQuery), params, nil
+	return tmpl.EvalContext(ctx, inputParams)
 }
 
-func build(tokens []token, inputParams map[string]interface{}) (string, []interface{}, error) {
+// build returns the query and bind values. redacted has Redacted instead of values of sensitive parameters,
+// and it is nil if no sensitive values are bound.
+func build(tokens []token, inputParams map[string]interface{}, o *options, sensitive *sensitiveSet) (string, []interface{}, []interface{}, error) {
 	var b strings.Builder
-	params := make([]interface{}, 0, len(tokens))
+	bd := newBinder(o.placeholder, len(tokens))
+	bd.sensitive = sensitive
 
 	for _, token := range tokens {
 		if token.kind == tkBind {
-			if elem, ok := inputParams[token.value]; ok {
-				switch elemTyp := elem.(type) {
-				case []string:
-					token.str = bindLiterals(token.str, len(elemTyp))
-					for _, value := range elemTyp {
-						params = append(params, value)
+			elem, err := lookupParam(inputParams, token.value)
+			if err != nil {
+				return "", nil, nil, err
+			}
+			if elem, err = o.converters.bindValue(elem); err != nil {
+				return "", nil, nil, err
+			}
+			if rv, ok := bindSlice(elem); !ok {
+				token.str = bd.bind(token.value, elem) + strings.TrimPrefix(token.str, "?")
+			} else if rv.Len() == 0 {
+				switch o.emptySlice {
+				case EmptySliceNull:
+					token.str = "(NULL)" + strings.TrimPrefix(token.str, "?")
+				default:
+					return "", nil, nil, fmt.Errorf("empty slice is bound to %s", token.value)
+				}
+			} else if columns, isRecord := recordColumns(rv.Index(0).Interface(), token.columns); isRecord {
+				if len(columns) == 0 {
+					return "", nil, nil, fmt.Errorf("column list is required to bind %s: /*%s(column1, column2)*/", token.value, token.value)
+				}
+				placeholders := make([][]string, rv.Len())
+				rowSensitive := sensitiveParams(&options{}, normalizeValue(rv.Index(0).Interface()))
+				for i := 0; i < rv.Len(); i++ {
+					row := map[string]interface{}{}
+					if err := encodeRecord(row, rv.Index(i)); err != nil {
+						return "", nil, nil, err
 					}
-				case []int:
-					token.str = bindLiterals(token.str, len(elemTyp))
-					for _, value := range elemTyp {
-						params = append(params, value)
+					placeholders[i] = make([]string, len(columns))
+					for j, column := range columns {
+						value, ok := row[column]
+						if !ok {
+							return "", nil, nil, fmt.Errorf("row %d of %s doesn't have column %s", i, token.value, column)
+						}
+						if value, err = o.converters.bindValue(value); err != nil {
+							return "", nil, nil, err
+						}
+						path := elementPath(token.value, i) + "." + column
+						if rowSensitive.has(column) {
+							bd.markSensitive(path)
+						}
+						placeholders[i][j] = bd.bind(path, value)
 					}
-				case [][]interface{}:
-					token.str = bindTable(token.str, len(elemTyp), len(elemTyp[0]))
-					for _, rows := range elemTyp {
-						for _, columns := range rows {
-							params = append(params, columns)
+				}
+				token.str = bindTable(token.str, placeholders)
+			} else if _, isTable := bindSlice(rv.Index(0).Interface()); isTable {
+				placeholders := make([][]string, rv.Len())
+				for i := 0; i < rv.Len(); i++ {
+					row, ok := bindSlice(rv.Index(i).Interface())
+					if !ok || row.Len() == 0 {
+						return "", nil, nil, fmt.Errorf("row %d of %s must be non-empty slice, but %T", i, token.value, rv.Index(i).Interface())
+					}
+					placeholders[i] = make([]string, row.Len())
+					for j := 0; j < row.Len(); j++ {
+						value, err := o.converters.bindValue(row.Index(j).Interface())
+						if err != nil {
+							return "", nil, nil, err
 						}
+						placeholders[i][j] = bd.bind(elementPath(elementPath(token.value, i), j), value)
 					}
-				default:
-					params = append(params, elem)
 				}
+				token.str = bindTable(token.str, placeholders)
 			} else {
-				return "", nil, fmt.Errorf("no parameter that matches the bind value: %s", token.value)
+				placeholders := make([]string, rv.Len())
+				for i := 0; i < rv.Len(); i++ {
+					value, err := o.converters.bindValue(rv.Index(i).Interface())
+					if err != nil {
+						return "", nil, nil, err
+					}
+					placeholders[i] = bd.bind(elementPath(token.value, i), value)
+				}
+				token.str = bindLiterals(token.str, placeholders)
 			}
 		}
 		b.WriteString(token.str)
 	}
-	return b.String(), params, nil
+	return b.String(), bd.params, bd.redacted, nil
+}
+
+var valuerType = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+
+// bindSlice returns reflect.Value of v if v is expanded to multiple placeholders.
+// []byte and [N]byte (e.g. UUID) and types that implement driver.Valuer are bound as a single value.
+func bindSlice(v interface{}) (reflect.Value, bool) {
+	if v == nil {
+		return reflect.Value{}, false
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Type().Implements(valuerType) {
+		return reflect.Value{}, false
+	}
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return reflect.Value{}, false
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return reflect.Value{}, false
+	}
+	if rv.Type().Elem().Kind() == reflect.Uint8 || rv.Type().Implements(valuerType) {
+		return reflect.Value{}, false
+	}
+	return rv, true
+}
+
+func elementPath(path string, i int) string {
+	return path + "." + strconv.Itoa(i)
+}
+
+// recordColumns returns columns of a row if v is a struct with tags or a map.
+// columns is the column list in the template like /*people(first_name,last_name)*/.
+// If it is empty, tag order of the struct is used. Column order of maps can't be decided without the list.
+func recordColumns(v interface{}, columns string) ([]string, bool) {
+	v = normalizeValue(v)
+	if v == nil {
+		return nil, false
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil, false
+		}
+	case reflect.Struct:
+		if rv.Type().Implements(valuerType) {
+			return nil, false
+		}
+		tagged := tagColumns(rv.Type(), []string{"twowaysql", "db"}, nil)
+		if len(tagged) == 0 {
+			return nil, false
+		}
+		if columns == "" {
+			return tagged, true
+		}
+	default:
+		return nil, false
+	}
+	if columns == "" {
+		return nil, true
+	}
+	return strings.Split(columns, ","), true
+}
+
+// tagColumns returns tag names in the same order as struct fields. Nested structs are flattened like encode.
+func tagColumns(typ reflect.Type, tags []string, dest []string) []string {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tagValue := getTagValue(field.Tag, tags)
+		if field.Type.Kind() == reflect.Struct {
+			switch field.Type.PkgPath() {
+			case "database/sql", "time":
+			default:
+				dest = tagColumns(field.Type, tags, dest)
+				continue
+			}
+		}
+		if tagValue != "" && tagValue != "-" {
+			dest = append(dest, tagValue)
+		}
+	}
+	return dest
+}
+
+// encodeRecord encodes a row of struct slice or map slice
+func encodeRecord(dest map[string]interface{}, v reflect.Value) error {
+	return encode(dest, recordParams(v))
+}
+
+// recordParams returns a row of struct slice or map slice as params of encode.
+// Rows in interface slices like []any{Person{}} are unwrapped as well as typed slices.
+func recordParams(v reflect.Value) interface{} {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return nil
+	}
+	if v.Kind() == reflect.Struct {
+		// encode requires pointer of struct
+		p := reflect.New(v.Type())
+		p.Elem().Set(v)
+		v = p
+	}
+	return v.Interface()
 }
 
 // ?/* ... */ -> (?, ?, ?)/* ... */みたいにする
-func bindLiterals(str string, number int) string {
+func bindLiterals(str string, placeholders []string) string {
 	str = strings.TrimLeftFunc(str, func(r rune) bool {
 		return r != unicode.SimpleFold('/')
 	})
 	var b strings.Builder
 	b.WriteRune('(')
-	for i := 0; i < number; i++ {
-		b.WriteRune('?')
-		if i != number-1 {
-			b.WriteString(", ")
-		}
-	}
+	b.WriteString(strings.Join(placeholders, ", "))
 	b.WriteRune(')')
 
 	return fmt.Sprint(b.String(), str)
 }
 
-func bindTable(str string, rowNumber, columnNumber int) string {
+// ?/* ... */ -> ((?, ?), (?, ?))/* ... */みたいにする
+func bindTable(str string, placeholders [][]string) string {
 	str = strings.TrimLeftFunc(str, func(r rune) bool {
 		return r != unicode.SimpleFold('/')
 	})
 
-	var column strings.Builder
-	column.WriteRune('(')
-	for i := 0; i < columnNumber; i++ {
-		column.WriteRune('?')
-		if i != columnNumber-1 {
-			column.WriteString(", ")
-		}
-	}
-	column.WriteRune(')')
-
 	var row strings.Builder
 	row.WriteRune('(')
-	for i := 0; i < rowNumber; i++ {
-		row.WriteString(column.String())
-		if i != rowNumber-1 {
+	for i, columns := range placeholders {
+		row.WriteRune('(')
+		row.WriteString(strings.Join(columns, ", "))
+		row.WriteRune(')')
+		if i != len(placeholders)-1 {
 			row.WriteString(", ")
 		}
 	}
@@ -128,18 +266,22 @@ func bindTable(str string, rowNumber, columnNumber int) string {
 }
 
 // 空白が二つ以上続いていたら一つにする。=1 -> = 1のような変換はできない
-// 単純な空白を想定。 -> issue: よりロバストな実装
+// 文字列リテラル、引用符付き識別子、コメントの中は変更しない
 func arrangeWhiteSpace(str string) string {
-	ret := ""
-	buff := bytes.NewBufferString(ret)
-	for i := 0; i < len(str); i++ {
-		if i < len(str)-1 && str[i] == ' ' && str[i+1] == ' ' {
+	buff := bytes.NewBufferString("")
+	for _, s := range splitLiterals(str) {
+		if s.literal {
+			buff.WriteString(s.str)
 			continue
 		}
-		buff.WriteByte(str[i])
+		for i := 0; i < len(s.str); i++ {
+			if i < len(s.str)-1 && s.str[i] == ' ' && s.str[i+1] == ' ' {
+				continue
+			}
+			buff.WriteByte(s.str[i])
+		}
 	}
-	ret = buff.String()
-	return strings.Trim(ret, " ")
+	return strings.Trim(buff.String(), " ")
 }
 
 type encoder struct {
@@ -147,6 +289,7 @@ type encoder struct {
 }
 
 func (m encoder) ParseTag(name, tagKey, tagStr, pathStr string, elemType reflect.Type) (tag interface{}, err error) {
+	tagStr, _ = parseTag(tagStr)
 	return runtimescan.BasicParseTag(name, tagKey, tagStr, pathStr, elemType)
 }
 
@@ -188,6 +331,22 @@ func encode(dest map[string]interface{}, src interface{}) error {
 	// tagscanner does not support nest struct type.
 	encodeStructField(src, dest, tags)
 
+	// fields of nested structs are flattened. report the same tags in different fields when they are used
+	typ := reflect.TypeOf(src)
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return nil
+	}
+	paths := make(map[string][]string)
+	tagPaths(typ, tags, "", paths)
+	for k, p := range paths {
+		if len(p) > 1 {
+			dest[k] = ambiguousParam{paths: p}
+		}
+	}
+
 	return nil
 }
 
@@ -201,6 +360,8 @@ func convertToMapStringAny(mp reflect.Value, dest map[string]interface{}) bool {
 	return true
 }
 
+// encodeStructField flattens fields of nested structs into dest.
+// Tagged nested structs are also stored as they are, so that they can be accessed by dotted paths like user.address.city.
 func encodeStructField(src interface{}, dest map[string]interface{}, tags []string) {
 	srcFieldValues := reflect.ValueOf(src)
 	srcFieldTyps := srcFieldValues.Type()
@@ -229,11 +390,49 @@ func encodeStructField(src interface{}, dest map[string]interface{}, tags []stri
 			}
 			encodeTimeTyp(srcFieldValue, dest, tagValue)
 		default:
+			if !srcFieldTyp.IsExported() {
+				continue
+			}
+			if tagValue != "" {
+				dest[tagValue] = srcFieldValue.Interface()
+			}
 			encodeStructField(srcFieldValue.Interface(), dest, tags)
 		}
 	}
 }
 
+// ambiguousParam is stored instead of values when fields of nested structs have the same tag
+type ambiguousParam struct {
+	paths []string
+}
+
+// tagPaths collects dotted paths of each tag in the same manner as encode flattens nested structs.
+func tagPaths(typ reflect.Type, tags []string, prefix string, dest map[string][]string) {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tagValue := getTagValue(field.Tag, tags)
+		if field.Type.Kind() == reflect.Struct {
+			switch field.Type.PkgPath() {
+			case "database/sql", "time":
+			default:
+				if tagValue != "" {
+					dest[tagValue] = append(dest[tagValue], prefix+tagValue)
+					tagPaths(field.Type, tags, prefix+tagValue+".", dest)
+				} else {
+					tagPaths(field.Type, tags, prefix, dest)
+				}
+				continue
+			}
+		}
+		if tagValue != "" {
+			dest[tagValue] = append(dest[tagValue], prefix+tagValue)
+		}
+	}
+}
+
 func encodeSQLNullTyp(srcFieldValue reflect.Value, dest map[string]interface{}, tagValue string) {
 	switch v := srcFieldValue.Interface().(type) {
 	case sql.NullBool:
@@ -243,8 +442,11 @@ func encodeSQLNullTyp(srcFieldValue reflect.Value, dest map[string]interface{},
 			dest[tagValue] = nil
 		}
 	case sql.NullByte:
-		// not support
-		return
+		if v.Valid {
+			dest[tagValue] = v.Byte
+		} else {
+			dest[tagValue] = nil
+		}
 	case sql.NullFloat64:
 		if v.Valid {
 			dest[tagValue] = v.Float64
@@ -281,6 +483,11 @@ func encodeSQLNullTyp(srcFieldValue reflect.Value, dest map[string]interface{},
 		} else {
 			dest[tagValue] = nil
 		}
+	default:
+		// sql.Null[T]
+		if inner, ok := unwrapSQLNull(srcFieldValue); ok {
+			dest[tagValue] = inner
+		}
 	}
 }
 
@@ -293,8 +500,7 @@ func encodeTimeTyp(srcFieldValue reflect.Value, dest map[string]interface{}, tag
 
 func getTagValue(structTag reflect.StructTag, targetTags []string) string {
 	for _, t := range targetTags {
-		tag := structTag.Get(t)
-		if tag != "" {
+		if tag, _ := parseTag(structTag.Get(t)); tag != "" {
 			return tag
 		}
 	}
@@ -2,6 +2,8 @@ package twowaysql
 
 import (
 	"database/sql"
+	"database/sql/driver"
+	"strings"
 	"testing"
 	"time"
 
@@ -781,6 +783,21 @@ func TestEval(t *testing.T) {
 			`,
 			wantParams: []interface{}{3, 12},
 		},
+		{
+			name:        "backslash escaped quote",
+			input:       `SELECT * FROM person WHERE name = 'O\'Reilly' AND employee_no < /*maxEmpNo*/1000`,
+			inputParams: Info{MaxEmpNo: 3},
+			wantQuery:   `SELECT * FROM person WHERE name = 'O\'Reilly' AND employee_no < ?/*maxEmpNo*/`,
+			wantParams:  []interface{}{3},
+		},
+		{
+			name: "if after newline",
+			input: `SELECT * FROM person WHERE employee_no < 1000 /*
+				IF maxEmpNo != null */ AND employee_no < /*maxEmpNo*/1000 /* END */`,
+			inputParams: Info{MaxEmpNo: 3},
+			wantQuery:   `SELECT * FROM person WHERE employee_no < 1000 AND employee_no < ?/*maxEmpNo*/`,
+			wantParams:  []interface{}{3},
+		},
 	}
 
 	for _, tt := range tests {
@@ -1066,34 +1083,34 @@ func TestGenerateAbnormal(t *testing.T) {
 		{
 			name:      "extra END 1",
 			input:     "SELECT * FROM person WHERE employee_no < 1000  AND dept_no = 1 /* END */",
-			wantError: "can not generate abstract syntax tree",
+			wantError: "can not parse: unexpected /* END */",
 		},
 		{
 			name:      "extra END 2",
 			input:     "SELECT * FROM person WHERE employee_no < 1000  /* END */ AND dept_no = 1 ",
-			wantError: "can not generate abstract syntax tree",
+			wantError: "can not parse: unexpected /* END */",
 		},
 		{
 			name:      "invalid Elif pos",
 			input:     `SELECT * FROM person WHERE employee_no < 1000 /* ELIF true */ AND dept_no = 1`,
-			wantError: "can not generate abstract syntax tree",
+			wantError: "can not parse: unexpected /* ELIF */",
 		},
 		{
 			name:      "not match if, elif and end",
 			input:     `SELECT * FROM person WHERE employee_no < 1000 /* IF true */ /* IF false */ AND dept_no =1 /* ELSE */ AND id=3 /* ELSE*/ AND boss_id=4 /* END */`,
-			wantError: "can not parse: expected /* END */, but got 4",
+			wantError: "can not parse: expected /* END */, but got /* ELSE */",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if query, params, err := Eval(tt.input, nil); err == nil || err.Error() != tt.wantError {
+			if query, params, err := Eval(tt.input, nil); err == nil || syntaxErrorMsg(err) != tt.wantError {
 				if err == nil {
 					t.Error("query", query)
 					t.Error("params", params)
 					t.Errorf("should return error")
 				} else {
-					t.Errorf("\nexpected:\n%v\nbut got\n%v\n", tt.wantError, err.Error())
+					t.Errorf("\nexpected:\n%v\nbut got\n%v\n", tt.wantError, syntaxErrorMsg(err))
 				}
 			}
 		})
@@ -1142,6 +1159,15 @@ func TestEvalWithMap(t *testing.T) {
 			wantQuery:  `SELECT * FROM person WHERE 1=1`,
 			wantParams: []interface{}{},
 		},
+		{
+			name:  "non-ASCII parameter in condition",
+			input: `SELECT * FROM person WHERE 1=1 /* IF 名前 */ AND name = /*名前*/'name' /* END */`,
+			inputParams: map[string]interface{}{
+				"名前": "Jeff",
+			},
+			wantQuery:  `SELECT * FROM person WHERE 1=1 AND name = ?/*名前*/`,
+			wantParams: []interface{}{"Jeff"},
+		},
 		{
 			name:  "bind table parameter",
 			input: `SELECT * FROM person WHERE name = /*name*/'name' AND (a, b) IN /*table*/(('x', 10), ('y', 11))`,
@@ -1347,3 +1373,516 @@ func TestEval_NestStructTyp(t *testing.T) {
 		})
 	}
 }
+
+func TestEval_For(t *testing.T) {
+	type Filter struct {
+		Column string `twowaysql:"column"`
+		Value  string `twowaysql:"value"`
+	}
+	type Group struct {
+		Name    string   `twowaysql:"name"`
+		Members []string `twowaysql:"members"`
+	}
+
+	tests := []struct {
+		name        string
+		input       string
+		inputParams map[string]interface{}
+		wantQuery   string
+		wantParams  []interface{}
+	}{
+		{
+			name:  "for with separator",
+			input: `SELECT * FROM person WHERE /* FOR item IN filters */ (first_name = /*item.value*/'x') /* SEPARATOR OR */ /* END */`,
+			inputParams: map[string]interface{}{
+				"filters": []Filter{{Value: "Jeff"}, {Value: "Tim"}, {Value: "Dan"}},
+			},
+			wantQuery:  `SELECT * FROM person WHERE (first_name = ?/*item.value*/) OR (first_name = ?/*item.value*/) OR (first_name = ?/*item.value*/)`,
+			wantParams: []interface{}{"Jeff", "Tim", "Dan"},
+		},
+		{
+			name:  "for with separator body",
+			input: `INSERT INTO person (first_name) VALUES /* FOR name IN names */(/*name*/'x')/* SEPARATOR */, /* END */`,
+			inputParams: map[string]interface{}{
+				"names": []string{"Jeff", "Tim"},
+			},
+			wantQuery:  `INSERT INTO person (first_name) VALUES (?/*name*/), (?/*name*/)`,
+			wantParams: []interface{}{"Jeff", "Tim"},
+		},
+		{
+			name:  "for without separator",
+			input: `SELECT * FROM person WHERE 1=1 /* FOR item IN filters */ AND first_name = /*item.value*/'x' /* END */ ORDER BY id`,
+			inputParams: map[string]interface{}{
+				"filters": []map[string]interface{}{{"value": "Jeff"}, {"value": "Tim"}},
+			},
+			wantQuery:  `SELECT * FROM person WHERE 1=1 AND first_name = ?/*item.value*/ AND first_name = ?/*item.value*/ ORDER BY id`,
+			wantParams: []interface{}{"Jeff", "Tim"},
+		},
+		{
+			name:  "empty collection",
+			input: `SELECT * FROM person WHERE 1=1 /* FOR item IN filters */ AND first_name = /*item.value*/'x' /* END */ ORDER BY id`,
+			inputParams: map[string]interface{}{
+				"filters": []Filter{},
+			},
+			wantQuery:  `SELECT * FROM person WHERE 1=1 ORDER BY id`,
+			wantParams: []interface{}{},
+		},
+		{
+			name:  "nil collection",
+			input: `SELECT * FROM person WHERE 1=1 /* FOR item IN filters */ AND first_name = /*item.value*/'x' /* END */ ORDER BY id`,
+			inputParams: map[string]interface{}{
+				"filters": nil,
+			},
+			wantQuery:  `SELECT * FROM person WHERE 1=1 ORDER BY id`,
+			wantParams: []interface{}{},
+		},
+		{
+			name:  "if in for",
+			input: `SELECT * FROM person WHERE 1=1 /* FOR item IN filters */ /* IF item.column == 'first_name' */ AND first_name = /*item.value*/'x' /* ELSE */ AND last_name = /*item.value*/'x' /* END */ /* END */`,
+			inputParams: map[string]interface{}{
+				"filters": []Filter{{Column: "first_name", Value: "Jeff"}, {Column: "last_name", Value: "Dean"}},
+			},
+			wantQuery:  `SELECT * FROM person WHERE 1=1 AND first_name = ?/*item.value*/ AND last_name = ?/*item.value*/`,
+			wantParams: []interface{}{"Jeff", "Dean"},
+		},
+		{
+			name:  "nested for",
+			input: `SELECT * FROM person WHERE /* FOR g IN groups */(dept = /*g.name*/'x' AND name IN (/* FOR m IN g.members */ /*m*/'x' /* SEPARATOR , */ /* END */))/* SEPARATOR OR */ /* END */`,
+			inputParams: map[string]interface{}{
+				"groups": []Group{{Name: "HR", Members: []string{"Jeff", "Tim"}}, {Name: "GA", Members: []string{"Dan"}}},
+			},
+			wantQuery:  `SELECT * FROM person WHERE (dept = ?/*g.name*/ AND name IN ( ?/*m*/ , ?/*m*/ )) OR (dept = ?/*g.name*/ AND name IN ( ?/*m*/ ))`,
+			wantParams: []interface{}{"HR", "Jeff", "Tim", "GA", "Dan"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, params, err := Eval(tt.input, tt.inputParams)
+			assert.NilError(t, err)
+			assert.Check(t, cmp.DeepEqual(tt.wantParams, params))
+			assert.Check(t, cmp.DeepEqual(tt.wantQuery, query))
+		})
+	}
+}
+
+func TestEval_ForShouldReturnError(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		inputParams map[string]interface{}
+		wantError   string
+	}{
+		{
+			name:        "invalid for",
+			input:       `SELECT * FROM person WHERE /* FOR item filters */ first_name = /*item*/'x' /* END */`,
+			inputParams: map[string]interface{}{},
+			wantError:   "invalid FOR comment: /* FOR item filters */ (/* FOR item IN items */ is expected)",
+		},
+		{
+			name:        "no END",
+			input:       `SELECT * FROM person WHERE /* FOR item IN filters */ first_name = /*item*/'x'`,
+			inputParams: map[string]interface{}{},
+			wantError:   "can not parse: not found /* END */",
+		},
+		{
+			name:        "no collection",
+			input:       `SELECT * FROM person WHERE /* FOR item IN filters */ first_name = /*item*/'x' /* END */`,
+			inputParams: map[string]interface{}{},
+			wantError:   "no parameter that matches the bind value: filters",
+		},
+		{
+			name:  "not a collection",
+			input: `SELECT * FROM person WHERE /* FOR item IN filters */ first_name = /*item*/'x' /* END */`,
+			inputParams: map[string]interface{}{
+				"filters": 10,
+			},
+			wantError: "FOR requires slice or array, but filters is int",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := Eval(tt.input, tt.inputParams)
+			assert.Equal(t, tt.wantError, syntaxErrorMsg(err))
+		})
+	}
+}
+
+type testValuer []string
+
+func (v testValuer) Value() (driver.Value, error) {
+	return strings.Join(v, ","), nil
+}
+
+func TestEval_Slice(t *testing.T) {
+	type IDs []int64
+	type UUID [16]byte
+
+	jst := time.FixedZone("JST", 9*60*60)
+	t1 := time.Date(2022, 7, 1, 0, 0, 0, 0, jst)
+	t2 := time.Date(2022, 7, 2, 0, 0, 0, 0, jst)
+
+	tests := []struct {
+		name        string
+		input       string
+		inputParams map[string]interface{}
+		wantQuery   string
+		wantParams  []interface{}
+	}{
+		{
+			name:        "int64",
+			input:       `SELECT * FROM person WHERE employee_no IN /*ids*/(1, 2)`,
+			inputParams: map[string]interface{}{"ids": []int64{1, 2, 3}},
+			wantQuery:   `SELECT * FROM person WHERE employee_no IN (?, ?, ?)/*ids*/`,
+			wantParams:  []interface{}{int64(1), int64(2), int64(3)},
+		},
+		{
+			name:        "named slice type",
+			input:       `SELECT * FROM person WHERE employee_no IN /*ids*/(1, 2)`,
+			inputParams: map[string]interface{}{"ids": IDs{1, 2}},
+			wantQuery:   `SELECT * FROM person WHERE employee_no IN (?, ?)/*ids*/`,
+			wantParams:  []interface{}{int64(1), int64(2)},
+		},
+		{
+			name:        "pointer to slice",
+			input:       `SELECT * FROM person WHERE employee_no IN /*ids*/(1, 2)`,
+			inputParams: map[string]interface{}{"ids": &[]int64{1, 2}},
+			wantQuery:   `SELECT * FROM person WHERE employee_no IN (?, ?)/*ids*/`,
+			wantParams:  []interface{}{int64(1), int64(2)},
+		},
+		{
+			name:        "array",
+			input:       `SELECT * FROM person WHERE employee_no IN /*ids*/(1, 2)`,
+			inputParams: map[string]interface{}{"ids": [2]int{1, 2}},
+			wantQuery:   `SELECT * FROM person WHERE employee_no IN (?, ?)/*ids*/`,
+			wantParams:  []interface{}{1, 2},
+		},
+		{
+			name:        "time",
+			input:       `SELECT * FROM person WHERE created_at IN /*times*/('2022-07-01')`,
+			inputParams: map[string]interface{}{"times": []time.Time{t1, t2}},
+			wantQuery:   `SELECT * FROM person WHERE created_at IN (?, ?)/*times*/`,
+			wantParams:  []interface{}{t1, t2},
+		},
+		{
+			name:        "any from JSON",
+			input:       `SELECT * FROM person WHERE first_name IN /*names*/('Jeff')`,
+			inputParams: map[string]interface{}{"names": []interface{}{"Jeff", "Dan"}},
+			wantQuery:   `SELECT * FROM person WHERE first_name IN (?, ?)/*names*/`,
+			wantParams:  []interface{}{"Jeff", "Dan"},
+		},
+		{
+			name:        "table",
+			input:       `SELECT * FROM person WHERE (first_name, last_name) IN /*names*/(('Jeff', 'Dean'))`,
+			inputParams: map[string]interface{}{"names": [][]string{{"Jeff", "Dean"}, {"Dan", "Conner"}}},
+			wantQuery:   `SELECT * FROM person WHERE (first_name, last_name) IN ((?, ?), (?, ?))/*names*/`,
+			wantParams:  []interface{}{"Jeff", "Dean", "Dan", "Conner"},
+		},
+		{
+			name:        "bytes",
+			input:       `SELECT * FROM person WHERE image = /*image*/'x'`,
+			inputParams: map[string]interface{}{"image": []byte("image")},
+			wantQuery:   `SELECT * FROM person WHERE image = ?/*image*/`,
+			wantParams:  []interface{}{[]byte("image")},
+		},
+		{
+			name:        "byte array",
+			input:       `SELECT * FROM person WHERE id IN /*ids*/('x')`,
+			inputParams: map[string]interface{}{"ids": []UUID{{1}, {2}}},
+			wantQuery:   `SELECT * FROM person WHERE id IN (?, ?)/*ids*/`,
+			wantParams:  []interface{}{UUID{1}, UUID{2}},
+		},
+		{
+			name:        "valuer",
+			input:       `SELECT * FROM person WHERE tags = /*tags*/'x'`,
+			inputParams: map[string]interface{}{"tags": testValuer{"a", "b"}},
+			wantQuery:   `SELECT * FROM person WHERE tags = ?/*tags*/`,
+			wantParams:  []interface{}{testValuer{"a", "b"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, params, err := Eval(tt.input, tt.inputParams)
+			assert.NilError(t, err)
+			assert.Check(t, cmp.DeepEqual(tt.wantParams, params))
+			assert.Check(t, cmp.DeepEqual(tt.wantQuery, query))
+		})
+	}
+}
+
+func TestEval_EmptySlice(t *testing.T) {
+	const query = `SELECT * FROM person WHERE employee_no IN /*ids*/(1, 2)`
+	params := map[string]interface{}{"ids": []int64{}}
+
+	_, _, err := Eval(query, params)
+	assert.Error(t, err, "empty slice is bound to ids")
+
+	_, _, err = Eval(query, map[string]interface{}{"ids": []int64(nil)})
+	assert.Error(t, err, "empty slice is bound to ids")
+
+	got, gotParams, err := Eval(query, params, WithEmptySlice(EmptySliceNull))
+	assert.NilError(t, err)
+	assert.Equal(t, `SELECT * FROM person WHERE employee_no IN (NULL)/*ids*/`, got)
+	assert.Check(t, cmp.DeepEqual([]interface{}{}, gotParams))
+
+	_, _, err = Eval(`INSERT INTO person (first_name, last_name) VALUES /*names*/('Jeff', 'Dean')`, map[string]interface{}{"names": [][]string{{"Jeff", "Dean"}, {}}})
+	assert.Error(t, err, "row 1 of names must be non-empty slice, but []string")
+}
+
+func TestEval_StructSlice(t *testing.T) {
+	type Name struct {
+		FirstName string `twowaysql:"first_name"`
+		LastName  string `db:"last_name"`
+	}
+	type Person struct {
+		EmpNo int `twowaysql:"employee_no"`
+		Name
+		Email sql.NullString `twowaysql:"email"`
+		Memo  string
+	}
+
+	people := []Person{
+		{EmpNo: 1, Name: Name{FirstName: "Jeff", LastName: "Dean"}, Email: sql.NullString{String: "jeff@example.com", Valid: true}},
+		{EmpNo: 2, Name: Name{FirstName: "Dan", LastName: "Conner"}},
+	}
+
+	tests := []struct {
+		name        string
+		input       string
+		inputParams map[string]interface{}
+		opts        []Option
+		wantQuery   string
+		wantParams  []interface{}
+	}{
+		{
+			name:        "tag order",
+			input:       `INSERT INTO persons (employee_no, first_name, last_name, email) VALUES /*people*/(1, 'Jeff', 'Dean', 'jeff@example.com')`,
+			inputParams: map[string]interface{}{"people": people},
+			wantQuery:   `INSERT INTO persons (employee_no, first_name, last_name, email) VALUES ((?, ?, ?, ?), (?, ?, ?, ?))/*people*/`,
+			wantParams:  []interface{}{1, "Jeff", "Dean", "jeff@example.com", 2, "Dan", "Conner", nil},
+		},
+		{
+			name:        "column list",
+			input:       `INSERT INTO persons (last_name, first_name) VALUES /*people(last_name, first_name)*/('Dean', 'Jeff')`,
+			inputParams: map[string]interface{}{"people": people},
+			wantQuery:   `INSERT INTO persons (last_name, first_name) VALUES ((?, ?), (?, ?))/*people(last_name, first_name)*/`,
+			wantParams:  []interface{}{"Dean", "Jeff", "Conner", "Dan"},
+		},
+		{
+			name:        "pointers",
+			input:       `INSERT INTO persons (first_name) VALUES /*people(first_name)*/('Jeff')`,
+			inputParams: map[string]interface{}{"people": []*Person{&people[0], &people[1]}},
+			wantQuery:   `INSERT INTO persons (first_name) VALUES ((?), (?))/*people(first_name)*/`,
+			wantParams:  []interface{}{"Jeff", "Dan"},
+		},
+		{
+			name:  "maps",
+			input: `INSERT INTO persons (first_name, last_name) VALUES /*people(first_name,last_name)*/('Jeff', 'Dean')`,
+			inputParams: map[string]interface{}{"people": []map[string]interface{}{
+				{"first_name": "Jeff", "last_name": "Dean"},
+				{"first_name": "Dan", "last_name": "Conner"},
+			}},
+			wantQuery:  `INSERT INTO persons (first_name, last_name) VALUES ((?, ?), (?, ?))/*people(first_name,last_name)*/`,
+			wantParams: []interface{}{"Jeff", "Dean", "Dan", "Conner"},
+		},
+		{
+			name:        "named placeholder",
+			input:       `INSERT INTO persons (first_name, last_name) VALUES /*people(first_name,last_name)*/('Jeff', 'Dean')`,
+			inputParams: map[string]interface{}{"people": people[:1]},
+			opts:        []Option{WithPlaceholder(Dollar)},
+			wantQuery:   `INSERT INTO persons (first_name, last_name) VALUES (($1, $2))/*people(first_name,last_name)*/`,
+			wantParams:  []interface{}{"Jeff", "Dean"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, params, err := Eval(tt.input, tt.inputParams, tt.opts...)
+			assert.NilError(t, err)
+			assert.Check(t, cmp.DeepEqual(tt.wantParams, params))
+			assert.Check(t, cmp.DeepEqual(tt.wantQuery, query))
+		})
+	}
+}
+
+func TestEval_StructSliceShouldReturnError(t *testing.T) {
+	type Person struct {
+		FirstName string `twowaysql:"first_name"`
+	}
+
+	tests := []struct {
+		name        string
+		input       string
+		inputParams map[string]interface{}
+		wantError   string
+	}{
+		{
+			name:  "maps without column list",
+			input: `INSERT INTO persons (first_name) VALUES /*people*/('Jeff')`,
+			inputParams: map[string]interface{}{"people": []map[string]interface{}{
+				{"first_name": "Jeff"},
+			}},
+			wantError: "column list is required to bind people: /*people(column1, column2)*/",
+		},
+		{
+			name:        "unknown column",
+			input:       `INSERT INTO persons (first_name, last_name) VALUES /*people(first_name,last_name)*/('Jeff', 'Dean')`,
+			inputParams: map[string]interface{}{"people": []Person{{FirstName: "Jeff"}}},
+			wantError:   "row 0 of people doesn't have column last_name",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := Eval(tt.input, tt.inputParams)
+			assert.Error(t, err, tt.wantError)
+		})
+	}
+}
+
+func TestEval_DottedPath(t *testing.T) {
+	type Address struct {
+		City string `twowaysql:"city"`
+	}
+	type User struct {
+		Name    string   `twowaysql:"name"`
+		Address *Address `twowaysql:"address"`
+	}
+	type Params struct {
+		User  User           `twowaysql:"user"`
+		Home  Address        `twowaysql:"home"`
+		Work  Address        `twowaysql:"work"`
+		Email sql.NullString `twowaysql:"email"`
+	}
+
+	params := &Params{
+		User:  User{Name: "Jeff", Address: &Address{City: "Tokyo"}},
+		Home:  Address{City: "Tokyo"},
+		Work:  Address{City: "Osaka"},
+		Email: sql.NullString{String: "jeff@example.com", Valid: true},
+	}
+
+	tests := []struct {
+		name        string
+		input       string
+		inputParams interface{}
+		wantQuery   string
+		wantParams  []interface{}
+	}{
+		{
+			name:        "nested struct",
+			input:       `SELECT * FROM person WHERE name = /*user.name*/'Jeff' /* IF user.address.city == 'Tokyo' */ AND city = /*user.address.city*/'Tokyo' /* END */`,
+			inputParams: params,
+			wantQuery:   `SELECT * FROM person WHERE name = ?/*user.name*/ AND city = ?/*user.address.city*/`,
+			wantParams:  []interface{}{"Jeff", "Tokyo"},
+		},
+		{
+			name:        "same tags in nested structs",
+			input:       `SELECT * FROM person WHERE home = /*home.city*/'Tokyo' AND work = /*work.city*/'Tokyo' AND email = /*email*/'x'`,
+			inputParams: params,
+			wantQuery:   `SELECT * FROM person WHERE home = ?/*home.city*/ AND work = ?/*work.city*/ AND email = ?/*email*/`,
+			wantParams:  []interface{}{"Tokyo", "Osaka", "jeff@example.com"},
+		},
+		{
+			name:  "nested map",
+			input: `SELECT * FROM person WHERE city = /*user.address.city*/'Tokyo' AND id = /*ids.1*/1`,
+			inputParams: map[string]interface{}{
+				"user": map[string]interface{}{
+					"address": map[string]string{"city": "Osaka"},
+				},
+				"ids": []int{1, 2},
+			},
+			wantQuery:  `SELECT * FROM person WHERE city = ?/*user.address.city*/ AND id = ?/*ids.1*/`,
+			wantParams: []interface{}{"Osaka", 2},
+		},
+		{
+			name:  "flattened field",
+			input: `SELECT * FROM person WHERE name = /*name*/'Jeff'`,
+			inputParams: &struct {
+				User User `twowaysql:"user"`
+			}{User: User{Name: "Jeff"}},
+			wantQuery:  `SELECT * FROM person WHERE name = ?/*name*/`,
+			wantParams: []interface{}{"Jeff"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, params, err := Eval(tt.input, tt.inputParams)
+			assert.NilError(t, err)
+			assert.Check(t, cmp.DeepEqual(tt.wantParams, params))
+			assert.Check(t, cmp.DeepEqual(tt.wantQuery, query))
+		})
+	}
+}
+
+func TestEval_DottedPathShouldReturnError(t *testing.T) {
+	type Address struct {
+		City string `twowaysql:"city"`
+	}
+	type User struct {
+		Name    string   `twowaysql:"name"`
+		Address *Address `twowaysql:"address"`
+	}
+	type Params struct {
+		User User    `twowaysql:"user"`
+		Home Address `twowaysql:"home"`
+		Work Address `twowaysql:"work"`
+	}
+	params := &Params{User: User{Name: "Jeff"}}
+
+	tests := []struct {
+		name        string
+		input       string
+		inputParams interface{}
+		wantError   string
+	}{
+		{
+			name:        "nil pointer",
+			input:       `SELECT * FROM person WHERE city = /*user.address.city*/'Tokyo'`,
+			inputParams: params,
+			wantError:   "can not resolve user.address.city: can not access 'city' of null",
+		},
+		{
+			name:        "missing field",
+			input:       `SELECT * FROM person WHERE city = /*user.city*/'Tokyo'`,
+			inputParams: params,
+			wantError:   "can not resolve user.city: twowaysql.User has no field 'city'",
+		},
+		{
+			name:        "missing root",
+			input:       `SELECT * FROM person WHERE city = /*person.city*/'Tokyo'`,
+			inputParams: params,
+			wantError:   "no parameter that matches the bind value: person.city",
+		},
+		{
+			name:  "missing key",
+			input: `SELECT * FROM person WHERE city = /*user.address.city*/'Tokyo'`,
+			inputParams: map[string]interface{}{
+				"user": map[string]interface{}{"address": map[string]string{}},
+			},
+			wantError: "can not resolve user.address.city: user.address has no key 'city'",
+		},
+		{
+			name:        "ambiguous bind",
+			input:       `SELECT * FROM person WHERE city = /*city*/'Tokyo'`,
+			inputParams: params,
+			wantError:   "parameter city is ambiguous (home.city, work.city). use dotted path instead",
+		},
+		{
+			name:        "ambiguous condition",
+			input:       `SELECT * FROM person /* IF city */ WHERE city = 'Tokyo' /* END */`,
+			inputParams: params,
+			wantError:   "parameter city is ambiguous (home.city, work.city). use dotted path instead",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := Eval(tt.input, tt.inputParams)
+			assert.Error(t, err, tt.wantError)
+		})
+	}
+}
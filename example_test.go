@@ -105,3 +105,28 @@ func ExampleEval() {
 	// [3 1 2 3]
 
 }
+
+func ExampleCompile() {
+
+	type Info struct {
+		MaxEmpNo int `twowaysql:"maxEmpNo"`
+		DeptNo   int `twowaysql:"deptNo"`
+	}
+
+	// parse once at startup, and evaluate many times
+	var tmpl = twowaysql.MustCompile(`SELECT * FROM person WHERE employee_no < /*maxEmpNo*/1000 /* IF deptNo */ AND dept_no < /*deptNo*/1 /* END */`)
+
+	after, afterParams, _ := tmpl.Eval(&Info{MaxEmpNo: 3})
+	fmt.Println(after)
+	fmt.Println(afterParams)
+
+	after, afterParams, _ = tmpl.Eval(&Info{MaxEmpNo: 3, DeptNo: 12})
+	fmt.Println(after)
+	fmt.Println(afterParams)
+
+	// Output:
+	// SELECT * FROM person WHERE employee_no < ?/*maxEmpNo*/
+	// [3]
+	// SELECT * FROM person WHERE employee_no < ?/*maxEmpNo*/ AND dept_no < ?/*deptNo*/
+	// [3 12]
+}
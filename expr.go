@@ -0,0 +1,565 @@
+package twowaysql
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxExprDepth limits nesting of condition expressions to keep parsing and evaluation cost bounded.
+const maxExprDepth = 64
+
+type exprTokenKind int
+
+const (
+	etEOF exprTokenKind = iota + 1
+	etIdent
+	etNumber
+	etString
+	etOperator
+)
+
+type exprToken struct {
+	kind exprTokenKind
+	str  string
+	pos  int
+}
+
+// exprOperators is sorted by length to match the longest operator first
+var exprOperators = []string{
+	"===", "!==",
+	"==", "!=", "<=", ">=", "&&", "||",
+	"<", ">", "!", "(", ")", "[", "]", ".", ",", "-",
+}
+
+func lexExpr(src string) ([]exprToken, error) {
+	var tokens []exprToken
+	i := 0
+	for i < len(src) {
+		c := src[i]
+		r, size := utf8.DecodeRuneInString(src[i:])
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case isIdentStart(r):
+			start := i
+			for i += size; i < len(src); i += size {
+				if r, size = utf8.DecodeRuneInString(src[i:]); !isIdentPart(r) {
+					break
+				}
+			}
+			tokens = append(tokens, exprToken{kind: etIdent, str: src[start:i], pos: start})
+		case '0' <= c && c <= '9':
+			start := i
+			for i < len(src) && ('0' <= src[i] && src[i] <= '9' || src[i] == '.' || src[i] == 'e' || src[i] == 'E') {
+				i++
+			}
+			tokens = append(tokens, exprToken{kind: etNumber, str: src[start:i], pos: start})
+		case c == '"' || c == '\'':
+			start := i
+			var b strings.Builder
+			i++
+			for i < len(src) && src[i] != c {
+				if src[i] == '\\' && i+1 < len(src) {
+					i++
+				}
+				b.WriteByte(src[i])
+				i++
+			}
+			if i >= len(src) {
+				return nil, fmt.Errorf("string literal is not terminated at %d", start)
+			}
+			i++
+			tokens = append(tokens, exprToken{kind: etString, str: b.String(), pos: start})
+		default:
+			matched := false
+			for _, op := range exprOperators {
+				if strings.HasPrefix(src[i:], op) {
+					tokens = append(tokens, exprToken{kind: etOperator, str: op, pos: i})
+					i += len(op)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("unexpected character '%c' at %d", r, i)
+			}
+		}
+	}
+	tokens = append(tokens, exprToken{kind: etEOF, pos: len(src)})
+	return tokens, nil
+}
+
+// isIdentStart and isIdentPart accept letters of any language like isIdentRune of the tokenizer
+func isIdentStart(r rune) bool {
+	return r == '_' || r == '$' || unicode.IsLetter(r)
+}
+
+func isIdentPart(r rune) bool {
+	return isIdentStart(r) || unicode.IsDigit(r)
+}
+
+// exprEnv is an environment to evaluate expressions
+type exprEnv struct {
+	params map[string]interface{}
+}
+
+// exprNode is a node of a compiled condition expression
+type exprNode interface {
+	eval(env *exprEnv) (interface{}, error)
+}
+
+type literalNode struct {
+	value interface{}
+}
+
+func (n literalNode) eval(env *exprEnv) (interface{}, error) {
+	return n.value, nil
+}
+
+type identNode struct {
+	name string
+}
+
+func (n identNode) eval(env *exprEnv) (interface{}, error) {
+	v, ok := env.params[n.name]
+	if !ok {
+		return nil, fmt.Errorf("'%s' is not defined", n.name)
+	}
+	return checkAmbiguous(n.name, v)
+}
+
+type memberNode struct {
+	x    exprNode
+	name string
+}
+
+func (n memberNode) eval(env *exprEnv) (interface{}, error) {
+	x, err := n.x.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	return memberValue(x, n.name)
+}
+
+type indexNode struct {
+	x     exprNode
+	index exprNode
+}
+
+func (n indexNode) eval(env *exprEnv) (interface{}, error) {
+	x, err := n.x.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	index, err := n.index.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	switch i := normalizeValue(index).(type) {
+	case string:
+		v, err = memberValue(x, i)
+	case float64:
+		v, err = memberValue(x, strconv.Itoa(int(i)))
+	case int:
+		v, err = memberValue(x, strconv.Itoa(i))
+	default:
+		return nil, fmt.Errorf("invalid index type %T", index)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+type lenNode struct {
+	x exprNode
+}
+
+func (n lenNode) eval(env *exprEnv) (interface{}, error) {
+	x, err := n.x.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	l, err := valueLength(x)
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+type arrayNode struct {
+	elems []exprNode
+}
+
+func (n arrayNode) eval(env *exprEnv) (interface{}, error) {
+	result := make([]interface{}, len(n.elems))
+	for i, e := range n.elems {
+		v, err := e.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = v
+	}
+	return result, nil
+}
+
+type notNode struct {
+	x exprNode
+}
+
+func (n notNode) eval(env *exprEnv) (interface{}, error) {
+	x, err := n.x.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	return !isTruthy(x), nil
+}
+
+type negNode struct {
+	x exprNode
+}
+
+func (n negNode) eval(env *exprEnv) (interface{}, error) {
+	x, err := n.x.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	f, ok := toNumber(normalizeValue(x))
+	if !ok {
+		return nil, fmt.Errorf("unary '-' requires number, but got %T", x)
+	}
+	return -f, nil
+}
+
+type logicalNode struct {
+	op   string
+	l, r exprNode
+}
+
+// logicalNode returns the last evaluated operand like JavaScript
+func (n logicalNode) eval(env *exprEnv) (interface{}, error) {
+	l, err := n.l.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	if (n.op == "&&") != isTruthy(l) {
+		return l, nil
+	}
+	return n.r.eval(env)
+}
+
+type binaryNode struct {
+	op   string
+	l, r exprNode
+}
+
+func (n binaryNode) eval(env *exprEnv) (interface{}, error) {
+	l, err := n.l.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	r, err := n.r.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "==":
+		return looseEqual(l, r), nil
+	case "!=":
+		return !looseEqual(l, r), nil
+	case "===":
+		return valueEqual(l, r), nil
+	case "!==":
+		return !valueEqual(l, r), nil
+	case "in":
+		return valueContains(r, l)
+	}
+	c, ok, err := looseCompare(l, r)
+	if err != nil {
+		return nil, fmt.Errorf("'%s' operator: %w", n.op, err)
+	}
+	if !ok {
+		// like JavaScript, comparison with NaN is false
+		return false, nil
+	}
+	switch n.op {
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	case ">=":
+		return c >= 0, nil
+	}
+	return nil, fmt.Errorf("unknown operator %s", n.op)
+}
+
+// exprParser is a recursive descent parser of condition expression.
+//
+// expr       = or
+// or         = and ("||" and)*
+// and        = equality ("&&" equality)*
+// equality   = relational (("==" | "!=" | "===" | "!==") relational)*
+// relational = unary (("<" | "<=" | ">" | ">=" | "in") unary)*
+// unary      = ("!" | "-") unary | postfix
+// postfix    = primary ("." ident | "[" expr "]")*
+// primary    = number | string | "true" | "false" | "null" | "undefined" |
+//
+//	ident | "len" "(" expr ")" | "(" expr ")" | "[" (expr ("," expr)*)? "]"
+type exprParser struct {
+	src    string
+	tokens []exprToken
+	index  int
+	depth  int
+}
+
+func parseExpr(src string) (exprNode, error) {
+	tokens, err := lexExpr(src)
+	if err != nil {
+		return nil, fmt.Errorf("invalid condition '%s': %w", src, err)
+	}
+	p := &exprParser{src: src, tokens: tokens}
+	node, err := p.expr()
+	if err == nil && p.peek().kind != etEOF {
+		err = p.unexpected()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid condition '%s': %w", src, err)
+	}
+	return node, nil
+}
+
+func (p *exprParser) peek() exprToken {
+	return p.tokens[p.index]
+}
+
+func (p *exprParser) consume(kind exprTokenKind, str string) bool {
+	t := p.tokens[p.index]
+	if t.kind == kind && t.str == str {
+		p.index++
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) expect(str string) error {
+	if !p.consume(etOperator, str) {
+		return fmt.Errorf("expected '%s' at %d", str, p.peek().pos)
+	}
+	return nil
+}
+
+func (p *exprParser) unexpected() error {
+	t := p.peek()
+	if t.kind == etEOF {
+		return errors.New("unexpected end of condition")
+	}
+	return fmt.Errorf("unexpected '%s' at %d", p.src[t.pos:], t.pos)
+}
+
+func (p *exprParser) expr() (exprNode, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxExprDepth {
+		return nil, errors.New("condition is nested too deeply")
+	}
+	return p.or()
+}
+
+func (p *exprParser) or() (exprNode, error) {
+	node, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.consume(etOperator, "||") {
+		r, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		node = logicalNode{op: "||", l: node, r: r}
+	}
+	return node, nil
+}
+
+func (p *exprParser) and() (exprNode, error) {
+	node, err := p.equality()
+	if err != nil {
+		return nil, err
+	}
+	for p.consume(etOperator, "&&") {
+		r, err := p.equality()
+		if err != nil {
+			return nil, err
+		}
+		node = logicalNode{op: "&&", l: node, r: r}
+	}
+	return node, nil
+}
+
+func (p *exprParser) equality() (exprNode, error) {
+	node, err := p.relational()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t.kind != etOperator || (t.str != "==" && t.str != "!=" && t.str != "===" && t.str != "!==") {
+			return node, nil
+		}
+		p.index++
+		r, err := p.relational()
+		if err != nil {
+			return nil, err
+		}
+		node = binaryNode{op: t.str, l: node, r: r}
+	}
+}
+
+func (p *exprParser) relational() (exprNode, error) {
+	node, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if !(t.kind == etOperator && (t.str == "<" || t.str == "<=" || t.str == ">" || t.str == ">=")) && !(t.kind == etIdent && t.str == "in") {
+			return node, nil
+		}
+		p.index++
+		r, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		node = binaryNode{op: t.str, l: node, r: r}
+	}
+}
+
+func (p *exprParser) unary() (exprNode, error) {
+	if p.consume(etOperator, "!") {
+		x, err := p.nested(p.unary)
+		if err != nil {
+			return nil, err
+		}
+		return notNode{x: x}, nil
+	}
+	if p.consume(etOperator, "-") {
+		x, err := p.nested(p.unary)
+		if err != nil {
+			return nil, err
+		}
+		return negNode{x: x}, nil
+	}
+	return p.postfix()
+}
+
+func (p *exprParser) postfix() (exprNode, error) {
+	node, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if p.consume(etOperator, ".") {
+			t := p.peek()
+			if t.kind != etIdent {
+				return nil, p.unexpected()
+			}
+			p.index++
+			node = memberNode{x: node, name: t.str}
+		} else if p.consume(etOperator, "[") {
+			index, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			node = indexNode{x: node, index: index}
+		} else {
+			return node, nil
+		}
+	}
+}
+
+func (p *exprParser) primary() (exprNode, error) {
+	t := p.peek()
+	switch t.kind {
+	case etNumber:
+		p.index++
+		f, err := strconv.ParseFloat(t.str, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number '%s' at %d", t.str, t.pos)
+		}
+		return literalNode{value: f}, nil
+	case etString:
+		p.index++
+		return literalNode{value: t.str}, nil
+	case etIdent:
+		p.index++
+		switch t.str {
+		case "true":
+			return literalNode{value: true}, nil
+		case "false":
+			return literalNode{value: false}, nil
+		case "null", "undefined":
+			return literalNode{value: nil}, nil
+		case "len":
+			if p.consume(etOperator, "(") {
+				x, err := p.expr()
+				if err != nil {
+					return nil, err
+				}
+				if err := p.expect(")"); err != nil {
+					return nil, err
+				}
+				return lenNode{x: x}, nil
+			}
+		}
+		return identNode{name: t.str}, nil
+	case etOperator:
+		if p.consume(etOperator, "(") {
+			x, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return x, nil
+		}
+		if p.consume(etOperator, "[") {
+			var elems []exprNode
+			if !p.consume(etOperator, "]") {
+				for {
+					e, err := p.expr()
+					if err != nil {
+						return nil, err
+					}
+					elems = append(elems, e)
+					if p.consume(etOperator, "]") {
+						break
+					}
+					if err := p.expect(","); err != nil {
+						return nil, err
+					}
+				}
+			}
+			return arrayNode{elems: elems}, nil
+		}
+	}
+	return nil, p.unexpected()
+}
+
+func (p *exprParser) nested(f func() (exprNode, error)) (exprNode, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxExprDepth {
+		return nil, errors.New("condition is nested too deeply")
+	}
+	return f()
+}
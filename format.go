@@ -0,0 +1,161 @@
+package twowaysql
+
+import (
+	"strings"
+)
+
+// Format is a mode of whitespace in evaluated queries.
+// String literals, quoted identifiers and comments are never changed in any mode.
+type Format int
+
+const (
+	// FormatCollapseSpaces collapses runs of spaces into one and trims spaces at both ends. Default.
+	FormatCollapseSpaces Format = iota
+	// FormatPreserve keeps whitespace of the query as it is.
+	FormatPreserve
+	// FormatLines keeps whitespace but removes blank lines left by IF/ELIF/ELSE/FOR/END comments and dropped blocks.
+	FormatLines
+	// FormatCompact converts the query into a single line. Line comments (-- ...) are removed.
+	FormatCompact
+)
+
+func (f Format) String() string {
+	switch f {
+	case FormatCollapseSpaces:
+		return "collapse"
+	case FormatPreserve:
+		return "preserve"
+	case FormatLines:
+		return "lines"
+	case FormatCompact:
+		return "compact"
+	default:
+		return "unknown"
+	}
+}
+
+// WithFormat sets how whitespace of evaluated queries is formatted.
+func WithFormat(f Format) Option {
+	return func(o *options) {
+		o.format = f
+	}
+}
+
+// dropMark is inserted where directives are removed when the format is FormatLines
+const dropMark = "\x00"
+
+// segment is a part of a query. literal is true for string literals, quoted identifiers and comments
+type segment struct {
+	str         string
+	literal     bool
+	lineComment bool
+}
+
+// splitLiterals splits query into literals and other parts.
+// An unterminated literal continues to the end of the query.
+func splitLiterals(query string) []segment {
+	var result []segment
+	l := &lexer{src: query}
+	for l.pos < len(l.src) {
+		begin := l.pos
+		var err error
+		lineComment := false
+		switch c := l.src[l.pos]; {
+		case strings.HasPrefix(l.src[l.pos:], "/*"):
+			err = l.skipComment()
+		case strings.HasPrefix(l.src[l.pos:], "--"):
+			l.skipLineComment()
+			lineComment = true
+		case c == '\'' || c == '"' || c == '`':
+			err = l.skipQuoted(c)
+		case c == '$':
+			err = l.skipDollarQuoted()
+			if err == nil && l.pos == begin+1 {
+				// $ not starting a dollar quote
+				l.pos = begin
+			}
+		}
+		if err != nil {
+			l.pos = len(l.src)
+		}
+		if l.pos > begin {
+			if l.start < begin {
+				result = append(result, segment{str: l.src[l.start:begin]})
+			}
+			result = append(result, segment{str: l.src[begin:l.pos], literal: true, lineComment: lineComment})
+			l.start = l.pos
+			continue
+		}
+		l.pos++
+	}
+	if l.start < len(l.src) {
+		result = append(result, segment{str: l.src[l.start:]})
+	}
+	return result
+}
+
+// format arranges whitespace of the evaluated query
+func format(query string, f Format) string {
+	switch f {
+	case FormatPreserve:
+		return query
+	case FormatLines:
+		return removeDroppedLines(query)
+	case FormatCompact:
+		return compact(query)
+	default:
+		return arrangeWhiteSpace(query)
+	}
+}
+
+// removeDroppedLines removes lines that have only whitespace and dropMark
+func removeDroppedLines(query string) string {
+	if !strings.Contains(query, dropMark) {
+		return query
+	}
+	lines := strings.SplitAfter(query, "\n")
+	var b strings.Builder
+	for _, line := range lines {
+		if strings.Contains(line, dropMark) && strings.TrimSpace(strings.ReplaceAll(line, dropMark, "")) == "" {
+			continue
+		}
+		b.WriteString(strings.ReplaceAll(line, dropMark, ""))
+	}
+	return b.String()
+}
+
+// compact replaces runs of whitespace with a single space except in literals and removes line comments
+func compact(query string) string {
+	var b strings.Builder
+	space := false
+	for _, s := range splitLiterals(query) {
+		if s.lineComment {
+			space = true
+			continue
+		}
+		if s.literal {
+			if space && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			space = false
+			b.WriteString(s.str)
+			continue
+		}
+		for i := 0; i < len(s.str); i++ {
+			if isSpace(s.str[i]) {
+				space = true
+				continue
+			}
+			if space && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			space = false
+			b.WriteByte(s.str[i])
+		}
+	}
+	return b.String()
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
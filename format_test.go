@@ -0,0 +1,92 @@
+package twowaysql
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestEval_Format(t *testing.T) {
+	query := "SELECT  *\n" +
+		"FROM person\n" +
+		"WHERE  name <> 'a  b'\n" +
+		"\n" +
+		"  /* IF deptNo */\n" +
+		"  AND dept_no = /*deptNo*/1\n" +
+		"  /* END */\n" +
+		"  /* IF email */AND email = /*email*/'x'/* END */ -- email  filter\n" +
+		"ORDER BY \"first  name\""
+	params := map[string]interface{}{"deptNo": 10, "email": ""}
+
+	tests := []struct {
+		name   string
+		format Format
+		want   string
+	}{
+		{
+			name:   "collapse spaces (default)",
+			format: FormatCollapseSpaces,
+			want: "SELECT *\n" +
+				"FROM person\n" +
+				"WHERE name <> 'a  b'\n" +
+				"\n" +
+				" \n" +
+				" AND dept_no = ?/*deptNo*/\n" +
+				" \n" +
+				" -- email  filter\n" +
+				"ORDER BY \"first  name\"",
+		},
+		{
+			name:   "preserve",
+			format: FormatPreserve,
+			want: "SELECT  *\n" +
+				"FROM person\n" +
+				"WHERE  name <> 'a  b'\n" +
+				"\n" +
+				"  \n" +
+				"  AND dept_no = ?/*deptNo*/\n" +
+				"  \n" +
+				"   -- email  filter\n" +
+				"ORDER BY \"first  name\"",
+		},
+		{
+			name:   "lines",
+			format: FormatLines,
+			want: "SELECT  *\n" +
+				"FROM person\n" +
+				"WHERE  name <> 'a  b'\n" +
+				"\n" +
+				"  AND dept_no = ?/*deptNo*/\n" +
+				"   -- email  filter\n" +
+				"ORDER BY \"first  name\"",
+		},
+		{
+			name:   "compact",
+			format: FormatCompact,
+			want:   "SELECT * FROM person WHERE name <> 'a  b' AND dept_no = ?/*deptNo*/ ORDER BY \"first  name\"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := Eval(query, params, WithFormat(tt.format))
+			assert.NilError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestEval_FormatLinesInLoop(t *testing.T) {
+	query := "SELECT * FROM person WHERE\n" +
+		"/* FOR name IN names */\n" +
+		"  first_name = /*name*/'x'\n" +
+		"  /* SEPARATOR OR */\n" +
+		"/* END */\n" +
+		"ORDER BY id"
+	got, _, err := Eval(query, map[string]interface{}{"names": []string{"Jeff", "Dan"}}, WithFormat(FormatLines))
+	assert.NilError(t, err)
+	assert.Equal(t, "SELECT * FROM person WHERE\n"+
+		"  first_name = ?/*name*/\n"+
+		"   OR \n"+
+		"  first_name = ?/*name*/\n"+
+		"ORDER BY id", got)
+}
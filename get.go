@@ -0,0 +1,106 @@
+package twowaysql
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Getter runs queries that return a single row. It is implemented by Twowaysql and TwowaysqlTx,
+// so Scalar and ScalarTemplate can be used in and out of transactions.
+type Getter interface {
+	Get(ctx context.Context, dest interface{}, query string, params interface{}) error
+	GetTemplate(ctx context.Context, dest interface{}, tmpl *Template, params interface{}) error
+}
+
+var (
+	_ Getter = (*Twowaysql)(nil)
+	_ Getter = (*TwowaysqlTx)(nil)
+)
+
+// Get is a thin wrapper around db.Get in the sqlx package.
+// params takes a tagged struct. The tags format must be `twowaysql:"tag_name"`.
+// dest takes a pointer to a struct, a pointer to a scannable value for a single column, or *map[string]interface{}.
+// The struct tag format must be `db:"tag_name"`.
+// It returns sql.ErrNoRows if no rows match. Rows other than the first one are discarded.
+func (t *Twowaysql) Get(ctx context.Context, dest interface{}, query string, params interface{}) error {
+	tmpl, err := Compile(query, t.opts...)
+	if err != nil {
+		return reportError(ctx, t.hooks, "Get", t.db.DriverName(), nil, err)
+	}
+	return t.GetTemplate(ctx, dest, tmpl, params)
+}
+
+// GetTemplate is like Get but takes a precompiled template.
+func (t *Twowaysql) GetTemplate(ctx context.Context, dest interface{}, tmpl *Template, params interface{}) error {
+	eval, bindParams, redacted, err := tmpl.eval(ctx, params)
+	if err != nil {
+		return reportError(ctx, t.hooks, "Get", t.db.DriverName(), tmpl, err)
+	}
+
+	q := tmpl.rebind(eval, t.db.Rebind)
+
+	event := newQueryEvent("Get", t.db.DriverName(), tmpl, q, bindParams, redacted)
+	return runHooks(ctx, t.hooks, event, func(ctx context.Context) error {
+		return redactError(getContext(ctx, t.db, dest, q, bindParams), bindParams, redacted)
+	})
+}
+
+// Get is a thin wrapper around db.Get in the sqlx package.
+// It is an equivalent implementation of Twowaysql.Get
+func (t *TwowaysqlTx) Get(ctx context.Context, dest interface{}, query string, params interface{}) error {
+	tmpl, err := Compile(query, t.opts...)
+	if err != nil {
+		return reportError(ctx, t.hooks, "Get", t.tx.DriverName(), nil, err)
+	}
+	return t.GetTemplate(ctx, dest, tmpl, params)
+}
+
+// GetTemplate is like Get but takes a precompiled template.
+// It is an equivalent implementation of Twowaysql.GetTemplate
+func (t *TwowaysqlTx) GetTemplate(ctx context.Context, dest interface{}, tmpl *Template, params interface{}) error {
+	eval, bindParams, redacted, err := tmpl.eval(ctx, params)
+	if err != nil {
+		return reportError(ctx, t.hooks, "Get", t.tx.DriverName(), tmpl, err)
+	}
+
+	q := tmpl.rebind(eval, t.tx.Rebind)
+
+	event := newQueryEvent("Get", t.tx.DriverName(), tmpl, q, bindParams, redacted)
+	return runHooks(ctx, t.hooks, event, func(ctx context.Context) error {
+		return redactError(getContext(ctx, t.tx, dest, q, bindParams), bindParams, redacted)
+	})
+}
+
+func getContext(ctx context.Context, q sqlx.QueryerContext, dest interface{}, query string, args []interface{}) error {
+	if destMap, ok := dest.(*map[string]interface{}); ok {
+		if *destMap == nil {
+			*destMap = map[string]interface{}{}
+		}
+		return q.QueryRowxContext(ctx, query, args...).MapScan(*destMap)
+	}
+	return sqlx.GetContext(ctx, q, dest, query, args...)
+}
+
+// Scalar runs a query that returns a single column like count(*) and returns the value of the first row.
+// It returns sql.ErrNoRows if no rows match. Use sql.Null[T] as T for nullable columns.
+//
+//	count, err := twowaysql.Scalar[int](ctx, tw, `SELECT count(*) FROM persons WHERE dept_no = /*dept_no*/1`, params)
+func Scalar[T any](ctx context.Context, db Getter, query string, params interface{}) (T, error) {
+	var result T
+	if err := db.Get(ctx, &result, query, params); err != nil {
+		var zero T
+		return zero, err
+	}
+	return result, nil
+}
+
+// ScalarTemplate is like Scalar but takes a precompiled template.
+func ScalarTemplate[T any](ctx context.Context, db Getter, tmpl *Template, params interface{}) (T, error) {
+	var result T
+	if err := db.GetTemplate(ctx, &result, tmpl, params); err != nil {
+		var zero T
+		return zero, err
+	}
+	return result, nil
+}
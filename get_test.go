@@ -0,0 +1,91 @@
+package twowaysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+type getPerson struct {
+	EmpNo     int            `db:"employee_no"`
+	FirstName string         `db:"first_name"`
+	LastName  sql.NullString `db:"last_name"`
+}
+
+func openGetDB(t *testing.T) *Twowaysql {
+	t.Helper()
+	tw := New(openSQLite(t))
+	_, err := tw.BulkExec(context.Background(), `INSERT INTO persons (employee_no, first_name, last_name) VALUES (/*employee_no*/1, /*first_name*/'Jeff', /*last_name*/'Dean')`, []map[string]any{
+		{"employee_no": 1, "first_name": "Jeff", "last_name": "Dean"},
+		{"employee_no": 2, "first_name": "Sanjay", "last_name": "Ghemawat"},
+		{"employee_no": 3, "first_name": "Ken", "last_name": nil},
+	})
+	assert.NilError(t, err)
+	return tw
+}
+
+func TestGet(t *testing.T) {
+	tw := openGetDB(t)
+	ctx := context.Background()
+
+	var person getPerson
+	err := tw.Get(ctx, &person, `SELECT * FROM persons WHERE employee_no = /*no*/1`, map[string]any{"no": 2})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, getPerson{EmpNo: 2, FirstName: "Sanjay", LastName: sql.NullString{String: "Ghemawat", Valid: true}}, person)
+
+	var name string
+	err = tw.Get(ctx, &name, `SELECT first_name FROM persons WHERE employee_no = /*no*/1`, map[string]any{"no": 3})
+	assert.NilError(t, err)
+	assert.Equal(t, "Ken", name)
+
+	var row map[string]interface{}
+	err = tw.Get(ctx, &row, `SELECT employee_no, first_name FROM persons WHERE employee_no = /*no*/1`, map[string]any{"no": 1})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, map[string]interface{}{"employee_no": int64(1), "first_name": "Jeff"}, row)
+
+	err = tw.Get(ctx, &person, `SELECT * FROM persons WHERE employee_no = /*no*/1`, map[string]any{"no": 10})
+	assert.Assert(t, errors.Is(err, sql.ErrNoRows))
+	row = nil
+	err = tw.Get(ctx, &row, `SELECT * FROM persons WHERE employee_no = /*no*/1`, map[string]any{"no": 10})
+	assert.Assert(t, errors.Is(err, sql.ErrNoRows))
+
+	// in transactions
+	err = tw.Transaction(ctx, func(tx *TwowaysqlTx) error {
+		if _, err := tx.Exec(ctx, `UPDATE persons SET first_name = /*name*/'Jeff' WHERE employee_no = 1`, map[string]any{"name": "Jeffrey"}); err != nil {
+			return err
+		}
+		return tx.GetTemplate(ctx, &name, MustCompile(`SELECT first_name FROM persons WHERE employee_no = /*no*/1`), map[string]any{"no": 1})
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, "Jeffrey", name)
+}
+
+func TestScalar(t *testing.T) {
+	tw := openGetDB(t)
+	ctx := context.Background()
+
+	count, err := Scalar[int](ctx, tw, `SELECT count(*) FROM persons WHERE employee_no > /*no*/1`, map[string]any{"no": 1})
+	assert.NilError(t, err)
+	assert.Equal(t, 2, count)
+
+	lastName, err := Scalar[sql.Null[string]](ctx, tw, `SELECT last_name FROM persons WHERE employee_no = /*no*/1`, map[string]any{"no": 3})
+	assert.NilError(t, err)
+	assert.Assert(t, !lastName.Valid)
+
+	_, err = Scalar[string](ctx, tw, `SELECT first_name FROM persons WHERE employee_no = /*no*/1`, map[string]any{"no": 10})
+	assert.Assert(t, errors.Is(err, sql.ErrNoRows))
+
+	_, err = Scalar[string](ctx, tw, `SELECT first_name, last_name FROM persons`, nil)
+	assert.ErrorContains(t, err, "scannable dest type string with >1 columns")
+
+	tmpl := MustCompile(`SELECT max(employee_no) FROM persons`)
+	err = tw.Transaction(ctx, func(tx *TwowaysqlTx) error {
+		maxNo, err := ScalarTemplate[int64](ctx, tx, tmpl, nil)
+		assert.Equal(t, int64(3), maxNo)
+		return err
+	})
+	assert.NilError(t, err)
+}
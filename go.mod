@@ -1,6 +1,6 @@
 module github.com/future-architect/go-twowaysql
 
-go 1.18
+go 1.22
 
 require (
 	github.com/alecthomas/chroma v0.10.0
@@ -0,0 +1,204 @@
+package twowaysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Hook is called around queries sent to the database by Twowaysql and TwowaysqlTx.
+// It is for logging, metrics and tracing.
+type Hook interface {
+	// Before is called before the query is sent. The returned context is used for the query and passed to After.
+	Before(ctx context.Context, event *QueryEvent) context.Context
+	// After is called after the query with the result, the error and the elapsed time.
+	After(ctx context.Context, event *QueryEvent)
+}
+
+// QueryEvent is a query passed to Hook.
+type QueryEvent struct {
+	// Op is the method name: "Select", "Get", "Exec" or "BulkExec".
+	Op string
+	// Driver is the driver name of the database.
+	Driver string
+	// Template is the original 2WaySQL query.
+	Template *Template
+	// Query is the evaluated query sent to the database.
+	// It is empty if the query fails before it is sent, like errors of the evaluation and parameter conversion.
+	// Template is nil if the compile fails.
+	Query string
+	// Args are the bind values of Query. Values of sensitive parameters are Redacted.
+	Args []interface{}
+
+	// Result is the result of Exec and BulkExec. It is set before After.
+	Result sql.Result
+	// Err is the error of the query. It is set before After.
+	Err error
+	// Elapsed is the time to run the query. It is set before After.
+	Elapsed time.Duration
+}
+
+// newQueryEvent returns an event that has redacted bind values if any
+func newQueryEvent(op, driver string, tmpl *Template, query string, args, redacted []interface{}) *QueryEvent {
+	if redacted != nil {
+		args = redacted
+	}
+	return &QueryEvent{Op: op, Driver: driver, Template: tmpl, Query: query, Args: args}
+}
+
+// WithHooks registers hooks that are called around queries.
+// It is used by New and the transactions started by Twowaysql.Begin inherit the hooks.
+// Before is called in the order of registration and After is called in the reverse order.
+func WithHooks(hooks ...Hook) Option {
+	return func(o *options) {
+		o.hooks = append(o.hooks, hooks...)
+	}
+}
+
+// runHooks runs fn between Before and After of hooks
+func runHooks(ctx context.Context, hooks []Hook, event *QueryEvent, fn func(ctx context.Context) error) error {
+	if len(hooks) == 0 {
+		return fn(ctx)
+	}
+	ctxs := make([]context.Context, len(hooks))
+	for i, h := range hooks {
+		ctx = h.Before(ctx, event)
+		ctxs[i] = ctx
+	}
+	start := time.Now()
+	event.Err = fn(ctx)
+	event.Elapsed = time.Since(start)
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hooks[i].After(ctxs[i], event)
+	}
+	return event.Err
+}
+
+// reportError calls hooks with an error that occurred before the query is sent, like compile and evaluation errors
+func reportError(ctx context.Context, hooks []Hook, op, driver string, tmpl *Template, err error) error {
+	return runHooks(ctx, hooks, newQueryEvent(op, driver, tmpl, "", nil, nil), func(ctx context.Context) error {
+		return err
+	})
+}
+
+// slogHook is a Hook returned by NewSlogHook
+type slogHook struct {
+	logger *slog.Logger
+}
+
+// NewSlogHook returns a Hook that logs queries to logger.
+// Successful queries are logged at Debug level and failed queries are logged at Error level.
+func NewSlogHook(logger *slog.Logger) Hook {
+	return &slogHook{logger: logger}
+}
+
+func (h *slogHook) Before(ctx context.Context, event *QueryEvent) context.Context {
+	return ctx
+}
+
+func (h *slogHook) After(ctx context.Context, event *QueryEvent) {
+	level := slog.LevelDebug
+	attrs := []slog.Attr{
+		slog.String("op", event.Op),
+		slog.String("query", event.Query),
+		slog.Any("args", event.Args),
+		slog.Duration("elapsed", event.Elapsed),
+	}
+	if source := event.source(); source != "" {
+		attrs = append(attrs, slog.String("source", source))
+	}
+	if event.Err != nil {
+		level = slog.LevelError
+		attrs = append(attrs, slog.String("error", event.Err.Error()))
+	} else if event.Result != nil {
+		if affected, err := event.Result.RowsAffected(); err == nil {
+			attrs = append(attrs, slog.Int64("rows_affected", affected))
+		}
+	}
+	h.logger.LogAttrs(ctx, level, "twowaysql query", attrs...)
+}
+
+// source returns the file and the line of the template set by WithSource
+func (e *QueryEvent) source() string {
+	if e.Template == nil || e.Template.opts.file == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", e.Template.opts.file, e.Template.opts.line)
+}
+
+// Tracer starts spans. It has the shape of OpenTelemetry's trace.Tracer,
+// so a tracer of OpenTelemetry can be used with a small wrapper without adding the dependency to this package.
+//
+//	type otelTracer struct{ trace.Tracer }
+//
+//	func (t otelTracer) Start(ctx context.Context, name string) (context.Context, twowaysql.Span) {
+//		ctx, span := t.Tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+//		return ctx, otelSpan{span}
+//	}
+//
+//	type otelSpan struct{ trace.Span }
+//
+//	func (s otelSpan) SetAttribute(key string, value any) {
+//		s.Span.SetAttributes(attribute.String(key, fmt.Sprint(value)))
+//	}
+//
+//	func (s otelSpan) RecordError(err error) {
+//		s.Span.RecordError(err)
+//		s.Span.SetStatus(codes.Error, err.Error())
+//	}
+//
+//	func (s otelSpan) End() { s.Span.End() }
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a span started by Tracer.
+type Span interface {
+	SetAttribute(key string, value any)
+	RecordError(err error)
+	End()
+}
+
+// tracingHook is a Hook returned by NewTracingHook
+type tracingHook struct {
+	tracer Tracer
+}
+
+type spanKey struct{}
+
+// NewTracingHook returns a Hook that records a span for each query.
+// The span is named "twowaysql.<Op>" and has attributes of the semantic conventions of OpenTelemetry
+// like "db.system.name" and "db.query.text". Bind values are not recorded.
+func NewTracingHook(tracer Tracer) Hook {
+	return &tracingHook{tracer: tracer}
+}
+
+func (h *tracingHook) Before(ctx context.Context, event *QueryEvent) context.Context {
+	ctx, span := h.tracer.Start(ctx, "twowaysql."+event.Op)
+	span.SetAttribute("db.system.name", event.Driver)
+	if event.Query != "" {
+		span.SetAttribute("db.query.text", event.Query)
+	}
+	if event.Template != nil && event.Template.opts.file != "" {
+		span.SetAttribute("code.filepath", event.Template.opts.file)
+		span.SetAttribute("code.lineno", event.Template.opts.line)
+	}
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+func (h *tracingHook) After(ctx context.Context, event *QueryEvent) {
+	span, ok := ctx.Value(spanKey{}).(Span)
+	if !ok {
+		return
+	}
+	if event.Err != nil {
+		span.RecordError(event.Err)
+	} else if event.Result != nil {
+		if affected, err := event.Result.RowsAffected(); err == nil {
+			span.SetAttribute("db.rows_affected", affected)
+		}
+	}
+	span.End()
+}
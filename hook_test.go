@@ -0,0 +1,235 @@
+package twowaysql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+type recordingHook struct {
+	name   string
+	calls  *[]string
+	events []QueryEvent
+}
+
+type hookNameKey struct{}
+
+func (h *recordingHook) Before(ctx context.Context, event *QueryEvent) context.Context {
+	*h.calls = append(*h.calls, h.name+".Before")
+	return context.WithValue(ctx, hookNameKey{}, h.name)
+}
+
+func (h *recordingHook) After(ctx context.Context, event *QueryEvent) {
+	*h.calls = append(*h.calls, h.name+".After:"+ctx.Value(hookNameKey{}).(string))
+	h.events = append(h.events, *event)
+}
+
+func TestHooks(t *testing.T) {
+	var calls []string
+	first := &recordingHook{name: "first", calls: &calls}
+	second := &recordingHook{name: "second", calls: &calls}
+	tw := New(openSQLite(t), WithHooks(first), WithHooks(second))
+	ctx := context.Background()
+
+	result, err := tw.Exec(ctx, `INSERT INTO persons (employee_no, first_name) VALUES (/*no*/1, /*name*/'Jeff')`, map[string]any{"no": 1, "name": "Jeff"})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{"first.Before", "second.Before", "second.After:second", "first.After:first"}, calls)
+
+	event := first.events[0]
+	assert.Equal(t, "Exec", event.Op)
+	assert.Equal(t, "sqlite", event.Driver)
+	assert.Equal(t, `INSERT INTO persons (employee_no, first_name) VALUES (/*no*/1, /*name*/'Jeff')`, event.Template.String())
+	assert.Equal(t, `INSERT INTO persons (employee_no, first_name) VALUES (?/*no*/, ?/*name*/)`, event.Query)
+	assert.DeepEqual(t, []any{1, "Jeff"}, event.Args)
+	assert.Equal(t, result, event.Result)
+	assert.NilError(t, event.Err)
+	assert.Assert(t, event.Elapsed > 0)
+
+	var people []map[string]any
+	err = tw.Select(ctx, &people, `SELECT * FROM unknown WHERE employee_no = /*no*/1`, map[string]any{"no": 1})
+	assert.ErrorContains(t, err, "no such table")
+	assert.Equal(t, "Select", first.events[1].Op)
+	assert.Equal(t, err, first.events[1].Err)
+
+	// transactions inherit hooks
+	err = tw.Transaction(ctx, func(tx *TwowaysqlTx) error {
+		if err := tx.Select(ctx, &people, `SELECT first_name FROM persons`, nil); err != nil {
+			return err
+		}
+		_, err := tx.BulkExec(ctx, `INSERT INTO persons (employee_no, first_name) VALUES (/*no*/2, /*name*/'Sanjay')`, []map[string]any{
+			{"no": 2, "name": "Sanjay"},
+			{"no": 3, "name": "Ken"},
+		})
+		return err
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, 4, len(second.events))
+	assert.Equal(t, "Select", second.events[2].Op)
+	assert.Equal(t, "BulkExec", second.events[3].Op)
+	assert.Equal(t, "INSERT INTO persons (employee_no, first_name) VALUES (?/*no*/, ?/*name*/), (?/*no*/, ?/*name*/)", second.events[3].Query)
+	assert.DeepEqual(t, []map[string]any{{"first_name": "Jeff"}}, people)
+}
+
+func TestSlogHook(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey || a.Key == "elapsed" {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+	tw := New(openSQLite(t), WithHooks(NewSlogHook(logger)))
+	ctx := context.Background()
+
+	tmpl, err := Compile(`INSERT INTO persons (employee_no, first_name) VALUES (/*no*/1, /*name*/'Jeff')`, WithSource("insert.sql", 3))
+	assert.NilError(t, err)
+	_, err = tw.ExecTemplate(ctx, tmpl, map[string]any{"no": 1, "name": "Jeff"})
+	assert.NilError(t, err)
+	_, err = tw.ExecTemplate(ctx, tmpl, map[string]any{"no": 1, "name": "Jeff"})
+	assert.ErrorContains(t, err, "UNIQUE constraint failed")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Equal(t, 2, len(lines))
+	var success, failure map[string]any
+	assert.NilError(t, json.Unmarshal([]byte(lines[0]), &success))
+	assert.NilError(t, json.Unmarshal([]byte(lines[1]), &failure))
+	assert.DeepEqual(t, map[string]any{
+		"level":         "DEBUG",
+		"msg":           "twowaysql query",
+		"op":            "Exec",
+		"query":         "INSERT INTO persons (employee_no, first_name) VALUES (?/*no*/, ?/*name*/)",
+		"args":          []any{float64(1), "Jeff"},
+		"source":        "insert.sql:3",
+		"rows_affected": float64(1),
+	}, success)
+	assert.Equal(t, "ERROR", failure["level"])
+	assert.Assert(t, strings.Contains(failure["error"].(string), "UNIQUE constraint failed"))
+}
+
+func TestTracingHook(t *testing.T) {
+	recorder := &spanRecorder{}
+	tw := New(openSQLite(t), WithHooks(NewTracingHook(recorder)))
+	ctx, parent := recorder.Start(context.Background(), "handler")
+
+	_, err := tw.Exec(ctx, `INSERT INTO persons (employee_no, first_name) VALUES (/*no*/1, /*name*/'Jeff')`, map[string]any{"no": 1, "name": "Jeff"})
+	assert.NilError(t, err)
+	var people []map[string]any
+	err = tw.Select(ctx, &people, `SELECT * FROM unknown`, nil)
+	assert.ErrorContains(t, err, "no such table")
+	err = tw.Select(ctx, &people, `SELECT first_name FROM persons`, nil)
+	assert.NilError(t, err)
+
+	spans := recorder.Spans()
+	assert.Equal(t, 3, len(spans))
+	assert.Equal(t, "twowaysql.Exec", spans[0].Name)
+	assert.Equal(t, parent, spans[0].Parent)
+	assert.DeepEqual(t, map[string]any{
+		"db.system.name":   "sqlite",
+		"db.query.text":    "INSERT INTO persons (employee_no, first_name) VALUES (?/*no*/, ?/*name*/)",
+		"db.rows_affected": int64(1),
+	}, spans[0].Attributes)
+	assert.Equal(t, "twowaysql.Select", spans[1].Name)
+	assert.ErrorContains(t, spans[1].Err, "no such table")
+	assert.NilError(t, spans[2].Err)
+
+	recorder.Reset()
+	assert.Equal(t, 0, len(recorder.Spans()))
+}
+
+func TestHooks_ErrorBeforeQuery(t *testing.T) {
+	var calls []string
+	hook := &recordingHook{name: "hook", calls: &calls}
+	tw := New(openSQLite(t), WithHooks(hook))
+	ctx := context.Background()
+	params := map[string]any{"no": 1}
+
+	var people []map[string]any
+	err := tw.Select(ctx, &people, `SELECT * FROM persons /* IF unknown */WHERE employee_no = /*no*/1 /* END */`, params)
+	assert.ErrorContains(t, err, "'unknown' is not defined")
+	_, err = tw.Exec(ctx, `DELETE FROM persons WHERE employee_no = /*no*/1 /* END */`, params)
+	assert.ErrorContains(t, err, "can not parse")
+	var name string
+	err = tw.Get(ctx, &name, `SELECT first_name FROM persons WHERE employee_no = /*unknown*/1`, params)
+	assert.ErrorContains(t, err, "no parameter that matches the bind value")
+	_, err = tw.BulkExec(ctx, `INSERT INTO persons (employee_no, first_name) VALUES (/*no*/1, /*name*/'Jeff')`, params)
+	assert.ErrorContains(t, err, "BulkExec requires slice of structs or maps")
+
+	assert.Equal(t, 4, len(hook.events))
+	for i, op := range []string{"Select", "Exec", "Get", "BulkExec"} {
+		event := hook.events[i]
+		assert.Equal(t, op, event.Op)
+		assert.Equal(t, "", event.Query)
+		assert.Assert(t, event.Err != nil)
+	}
+	assert.Assert(t, hook.events[0].Template != nil)
+	assert.Assert(t, hook.events[1].Template == nil)
+}
+
+// spanRecorder is an in-memory Tracer that keeps ended spans
+type spanRecorder struct {
+	lock  sync.Mutex
+	spans []*recordedSpan
+}
+
+// recordedSpan is a span recorded by spanRecorder
+type recordedSpan struct {
+	Name       string
+	Parent     *recordedSpan
+	Attributes map[string]any
+	Err        error
+
+	recorder *spanRecorder
+}
+
+type recordedSpanKey struct{}
+
+// Start starts a span. A span in ctx started by the recorder becomes the parent.
+func (r *spanRecorder) Start(ctx context.Context, name string) (context.Context, Span) {
+	span := &recordedSpan{
+		Name:       name,
+		Attributes: make(map[string]any),
+		recorder:   r,
+	}
+	span.Parent, _ = ctx.Value(recordedSpanKey{}).(*recordedSpan)
+	return context.WithValue(ctx, recordedSpanKey{}, span), span
+}
+
+// Spans returns ended spans in the order of ending.
+func (r *spanRecorder) Spans() []*recordedSpan {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]*recordedSpan(nil), r.spans...)
+}
+
+// Reset removes recorded spans.
+func (r *spanRecorder) Reset() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.spans = nil
+}
+
+// SetAttribute implements Span.
+func (s *recordedSpan) SetAttribute(key string, value any) {
+	s.Attributes[key] = value
+}
+
+// RecordError implements Span.
+func (s *recordedSpan) RecordError(err error) {
+	s.Err = err
+}
+
+// End implements Span.
+func (s *recordedSpan) End() {
+	s.recorder.lock.Lock()
+	defer s.recorder.lock.Unlock()
+	s.recorder.spans = append(s.recorder.spans, s)
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"io"
 	"io/fs"
+	"os"
+	"regexp"
 	"sort"
 	"strings"
 
@@ -14,6 +16,7 @@ import (
 // Document contains SQL and metadata
 type Document struct {
 	SQL               string       `json:"sql"`
+	SQLLine           int          `json:"sql_line,omitempty"`
 	Title             string       `json:"title"`
 	Params            []Param      `json:"params"`
 	CRUDMatrix        []CRUDMatrix `json:"crud_matrix,omitempty"`
@@ -41,10 +44,15 @@ type document struct {
 
 // Param is parameter type of 2-Way-SQL
 type Param struct {
-	Name        string    `json:"name"`
-	Type        ParamType `json:"type"`
-	Value       string    `json:"value"`
-	Description string    `json:"description,omitempty"`
+	Name          string    `json:"name"`
+	Type          ParamType `json:"type"`
+	Value         string    `json:"value"`
+	AllowedValues []string  `json:"allowed_values,omitempty"`
+	Description   string    `json:"description,omitempty"`
+	// Sensitive is true if the description has "(sensitive)". Values are redacted in logs and errors.
+	Sensitive bool `json:"sensitive,omitempty"`
+	// Line is the line number of the row in the parameter table. It is 0 if unknown.
+	Line int `json:"line,omitempty"`
 }
 
 // CRUDMatrix represents CRUD Matrix
@@ -69,6 +77,8 @@ type TestCase struct {
 	TestQuery string
 	Expect    [][]string
 	Fixtures  []Table
+	// Line is the line number of the heading of the test case. It is 0 if unknown.
+	Line int `json:",omitempty"`
 }
 
 type testCase struct {
@@ -101,23 +111,34 @@ func parseFixture(src string) (map[string][][]string, bool) {
 
 func parseExpect(src string) ([][]string, string, map[string]string, bool) {
 	tempSliceYaml := struct {
-		Param     map[string]string `yaml:"params"`
-		TestQuery string            `yaml:"testQuery"`
-		Expect    [][]string        `yaml:"expect"`
+		Param     map[string]yamlParam `yaml:"params"`
+		TestQuery string               `yaml:"testQuery"`
+		Expect    [][]string           `yaml:"expect"`
 	}{}
 	tempMapYaml := struct {
-		Param     map[string]string   `yaml:"params"`
-		TestQuery string              `yaml:"testQuery"`
-		Expect    []map[string]string `yaml:"expect"`
+		Param     map[string]yamlParam `yaml:"params"`
+		TestQuery string               `yaml:"testQuery"`
+		Expect    []map[string]string  `yaml:"expect"`
 	}{}
 	if err := yaml.Unmarshal([]byte(src), &tempSliceYaml); err == nil {
-		return tempSliceYaml.Expect, tempSliceYaml.TestQuery, tempSliceYaml.Param, true
+		return tempSliceYaml.Expect, tempSliceYaml.TestQuery, paramStrings(tempSliceYaml.Param), true
 	} else if err := yaml.Unmarshal([]byte(src), &tempMapYaml); err == nil {
-		return convertTableMapToSlice(tempMapYaml.Expect), tempMapYaml.TestQuery, tempMapYaml.Param, true
+		return convertTableMapToSlice(tempMapYaml.Expect), tempMapYaml.TestQuery, paramStrings(tempMapYaml.Param), true
 	}
 	return nil, "", nil, false
 }
 
+func paramStrings(params map[string]yamlParam) map[string]string {
+	if params == nil {
+		return nil
+	}
+	result := make(map[string]string, len(params))
+	for k, v := range params {
+		result[k] = string(v)
+	}
+	return result
+}
+
 var (
 	acceptableKeysInGlobalFixture = map[string]bool{
 		"fixtures": true,
@@ -201,6 +222,9 @@ func (d document) ToDocument() *Document {
 		Params:     d.Params,
 		CRUDMatrix: d.CRUDMatrix,
 	}
+	for i, p := range result.Params {
+		result.Params[i].Sensitive = sensitiveFlag.MatchString(p.Description)
+	}
 	switch d.RawCommonTestFixtureLang {
 	case "yaml":
 		result.CommonTestFixture = Fixture{
@@ -236,6 +260,7 @@ func init() {
 
 	docJig.Alias("Name").Lang("ja", "パラメータ名", "名前")
 	docJig.Alias("Type").Lang("ja", "型", "タイプ")
+	docJig.Alias("AllowedValues").Lang("ja", "許可値")
 	docJig.Alias("Description", "Desc", "Detail").Lang("ja", "説明", "詳細")
 
 	docJig.Alias("Table").Lang("ja", "テーブル")
@@ -250,12 +275,15 @@ func init() {
 	params := root.Child(".", "Parameters").Table("Params")
 	params.Field("Name", "Name").Required()
 	params.Field("Type").Required().As(func(typeName string, d *document) (any, error) {
-		t, ok := paramTypeMap[strings.ToLower(typeName)]
+		t, ok := lookupParamType(typeName)
 		if ok {
 			return t, nil
 		}
 		return nil, fmt.Errorf("type '%s' is invalid", typeName)
 	})
+	params.Field("AllowedValues").As(func(values string, d *document) (any, error) {
+		return parseAllowedValues(values), nil
+	})
 	params.Field("Description")
 
 	crudMatrix := root.Child(".", "CRUD Matrix").Table("CRUDMatrix")
@@ -273,22 +301,71 @@ func init() {
 	testcase.CodeFence("RawTest", "yaml")
 }
 
+// parseAllowedValues splits comma separated values like "id, first_name" in AllowedValues column
+func parseAllowedValues(src string) []string {
+	var result []string
+	for _, v := range strings.Split(src, ",") {
+		v = strings.Trim(strings.TrimSpace(v), "`")
+		if v != "" {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// sensitiveFlag marks parameters as sensitive in the description column
+var sensitiveFlag = regexp.MustCompile(`(?i)[(（]\s*(sensitive|機密)\s*[)）]`)
+
+// Options returns options to compile SQL of the document.
+// Allowed Values of Params are registered for raw substitutions (/*$name*/), and sensitive Params are registered by WithSensitive.
+func (d *Document) Options() []Option {
+	var opts []Option
+	var sensitive []string
+	for _, p := range d.Params {
+		if len(p.AllowedValues) > 0 {
+			opts = append(opts, WithAllowedValues(p.Name, p.AllowedValues...))
+		}
+		if p.Sensitive {
+			sensitive = append(sensitive, p.Name)
+		}
+	}
+	if len(sensitive) > 0 {
+		opts = append(opts, WithSensitive(sensitive...))
+	}
+	return opts
+}
+
+// RedactParams returns a copy of params that has Redacted instead of values of sensitive Params.
+// It is for logs and outputs.
+func (d *Document) RedactParams(params map[string]any) map[string]any {
+	result := make(map[string]any, len(params))
+	for k, v := range params {
+		result[k] = v
+	}
+	for _, p := range d.Params {
+		if _, ok := result[p.Name]; ok && p.Sensitive {
+			result[p.Name] = Redacted
+		}
+	}
+	return result
+}
+
 // ParseMarkdownFile parses markdown file
 func ParseMarkdownFile(filepath string) (*Document, error) {
-	d, err := docJig.ParseFile(filepath)
+	src, err := os.ReadFile(filepath)
 	if err != nil {
 		return nil, err
 	}
-	return d.ToDocument(), err
+	return ParseMarkdownString(string(src))
 }
 
 // ParseMarkdown parses markdown content
 func ParseMarkdown(r io.Reader) (*Document, error) {
-	d, err := docJig.Parse(r)
+	src, err := io.ReadAll(r)
 	if err != nil {
 		return nil, err
 	}
-	return d.ToDocument(), err
+	return ParseMarkdownString(string(src))
 }
 
 // ParseMarkdown parses markdown content
@@ -297,7 +374,9 @@ func ParseMarkdownString(src string) (*Document, error) {
 	if err != nil {
 		return nil, err
 	}
-	return d.ToDocument(), err
+	result := d.ToDocument()
+	result.setLines(src)
+	return result, err
 }
 
 // ParseMarkdownGlob parses markdown files to match patterns
@@ -309,6 +388,9 @@ func ParseMarkdownGlob(pattern ...string) (map[string]*Document, error) {
 	result := make(map[string]*Document)
 	for k, d := range ds {
 		result[k] = d.ToDocument()
+		if src, err := os.ReadFile(k); err == nil {
+			result[k].setLines(string(src))
+		}
 	}
 	return result, err
 }
@@ -322,10 +404,86 @@ func ParseMarkdownFS(fsys fs.FS, pattern ...string) (map[string]*Document, error
 	result := make(map[string]*Document)
 	for k, d := range ds {
 		result[k] = d.ToDocument()
+		if src, err := fs.ReadFile(fsys, k); err == nil {
+			result[k].setLines(string(src))
+		}
 	}
 	return result, err
 }
 
+// setLines sets line numbers of the SQL, the parameters and the test cases in the markdown source
+func (d *Document) setLines(src string) {
+	d.SQLLine = sqlLine(src, d.SQL)
+	lines := strings.Split(src, "\n")
+	for i := range d.Params {
+		d.Params[i].Line = paramLine(lines, d.Params[i].Name)
+	}
+	from := 0
+	for i := range d.TestCases {
+		if line := headingLine(lines, d.TestCases[i].Name, from); line > 0 {
+			d.TestCases[i].Line = line
+			from = line
+		}
+	}
+}
+
+// paramLine returns the line number of the table row whose first cell is name
+func paramLine(lines []string, name string) int {
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "|") {
+			continue
+		}
+		cells := strings.Split(strings.Trim(line, "|"), "|")
+		if strings.Trim(strings.TrimSpace(cells[0]), "`") == name {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// headingLine returns the line number of the heading that ends with name after the line from
+func headingLine(lines []string, name string, from int) int {
+	for i := from; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if !strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasSuffix(strings.TrimSpace(strings.TrimLeft(line, "#")), name) {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// sqlLine returns the line number where sql starts in the code fence of the markdown.
+// Pass it to WithSource to get the position of SyntaxError in the markdown file.
+// It returns 0 if the code fence is not found.
+func sqlLine(src, sql string) int {
+	firstLine := strings.TrimSpace(strings.SplitN(strings.TrimSpace(sql), "\n", 2)[0])
+	lines := strings.Split(src, "\n")
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "```") && !strings.HasPrefix(line, "~~~") {
+			continue
+		}
+		lang := strings.ToLower(strings.TrimSpace(strings.TrimLeft(line, "`~")))
+		if !strings.HasPrefix(lang, "sql") {
+			continue
+		}
+		// SQL may start after blank lines
+		for j := i + 1; j < len(lines); j++ {
+			if l := strings.TrimSpace(lines[j]); l != "" {
+				if l == firstLine {
+					return j + 1
+				}
+				break
+			}
+		}
+	}
+	return 0
+}
+
 // ParseMarkdown parses markdown content
 func GenerateMarkdown(w io.Writer, lang string) error {
 	return docJig.GenerateTemplate(w, mdd.GenerateOption{
@@ -3,6 +3,7 @@ package twowaysql
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 )
 
 // ParamType is for describing parameters
@@ -16,8 +17,14 @@ const (
 	IntType
 	TextType
 	TimestampType
+	DateType
+	DecimalType
+	JSONType
 )
 
+// arrayFlag is set to ParamType of arrays like "int[]"
+const arrayFlag ParamType = 1 << 8
+
 var paramTypeMap = map[string]ParamType{
 	"text":      TextType,
 	"string":    TextType,
@@ -34,9 +41,46 @@ var paramTypeMap = map[string]ParamType{
 	"timestamp": TimestampType,
 	"byte":      ByteType,
 	"tinyint":   ByteType,
+	"date":      DateType,
+	"decimal":   DecimalType,
+	"numeric":   DecimalType,
+	"json":      JSONType,
+	"jsonb":     JSONType,
+}
+
+// ArrayOf returns ParamType of arrays of elem. It is written as "int[]" in the parameter table.
+func ArrayOf(elem ParamType) ParamType {
+	return elem | arrayFlag
+}
+
+// IsArray returns true for arrays created by ArrayOf.
+func (p ParamType) IsArray() bool {
+	return p&arrayFlag != 0
+}
+
+// Elem returns ParamType of elements of arrays.
+func (p ParamType) Elem() ParamType {
+	return p &^ arrayFlag
+}
+
+// lookupParamType returns ParamType of names like "int" and "int[]"
+func lookupParamType(name string) (ParamType, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if elem, ok := strings.CutSuffix(name, "[]"); ok {
+		t, ok := paramTypeMap[strings.TrimSpace(elem)]
+		if !ok {
+			return InvalidType, false
+		}
+		return ArrayOf(t), true
+	}
+	t, ok := paramTypeMap[name]
+	return t, ok
 }
 
 func (p ParamType) String() string {
+	if p.IsArray() {
+		return p.Elem().String() + "[]"
+	}
 	switch p {
 	case InvalidType:
 		return "invalid"
@@ -52,6 +96,12 @@ func (p ParamType) String() string {
 		return "text"
 	case TimestampType:
 		return "timestamp"
+	case DateType:
+		return "date"
+	case DecimalType:
+		return "decimal"
+	case JSONType:
+		return "json"
 	default:
 		return "unknown"
 	}
@@ -66,7 +116,7 @@ func (p *ParamType) UnmarshalJSON(data []byte) error {
 	if err := json.Unmarshal(data, &s); err != nil {
 		return fmt.Errorf("data should be a string, got %s", data)
 	}
-	pt, ok := paramTypeMap[s]
+	pt, ok := lookupParamType(s)
 	if !ok {
 		return fmt.Errorf("invalid UserRole %s", s)
 	}
@@ -36,8 +36,9 @@ func TestParseMarkdown(t *testing.T) {
 				`),
 			},
 			want: &Document{
-				Title: "Search User Query",
-				SQL:   `SELECT email, name FROM persons WHERE first_name=/*first_name*/'bob';`,
+				Title:   "Search User Query",
+				SQL:     `SELECT email, name FROM persons WHERE first_name=/*first_name*/'bob';`,
+				SQLLine: 4,
 			},
 		},
 		{
@@ -58,13 +59,15 @@ func TestParseMarkdown(t *testing.T) {
 				`),
 			},
 			want: &Document{
-				Title: "Search User Query",
-				SQL:   `SELECT email, name FROM persons WHERE first_name=/*first_name*/'bob';`,
+				Title:   "Search User Query",
+				SQL:     `SELECT email, name FROM persons WHERE first_name=/*first_name*/'bob';`,
+				SQLLine: 4,
 				Params: []Param{
 					{
 						Name:        "first_name",
 						Type:        TextType,
 						Description: "search key",
+						Line:        11,
 					},
 				},
 			},
@@ -87,8 +90,9 @@ func TestParseMarkdown(t *testing.T) {
 				`),
 			},
 			want: &Document{
-				Title: "Search User Query",
-				SQL:   `SELECT email, name FROM persons WHERE first_name=/*first_name*/'bob';`,
+				Title:   "Search User Query",
+				SQL:     `SELECT email, name FROM persons WHERE first_name=/*first_name*/'bob';`,
+				SQLLine: 4,
 				CRUDMatrix: []CRUDMatrix{
 					{
 						Table: "persons",
@@ -213,8 +217,9 @@ func TestParseMarkdownTestCases(t *testing.T) {
 				`),
 			},
 			want: &Document{
-				Title: "Common Test Fixtures",
-				SQL:   "SELECT email FROM persons WHERE first_name=/*first_name*/'bob';",
+				Title:   "Common Test Fixtures",
+				SQL:     "SELECT email FROM persons WHERE first_name=/*first_name*/'bob';",
+				SQLLine: 4,
 				CommonTestFixture: Fixture{
 					Lang: "sql",
 					Code: "DELETE FROM persons;",
@@ -247,8 +252,9 @@ func TestParseMarkdownTestCases(t *testing.T) {
 				`),
 			},
 			want: &Document{
-				Title: "Test Cases",
-				SQL:   "SELECT email FROM persons WHERE first_name=/*first_name*/'bob';",
+				Title:   "Test Cases",
+				SQL:     "SELECT email FROM persons WHERE first_name=/*first_name*/'bob';",
+				SQLLine: 4,
 				TestCases: []TestCase{
 					{
 						Name: "select test",
@@ -265,6 +271,7 @@ func TestParseMarkdownTestCases(t *testing.T) {
 						Expect: [][]string{
 							{"email"}, {"evanmacmans@example.com"},
 						},
+						Line: 9,
 					},
 				},
 			},
@@ -296,8 +303,9 @@ func TestParseMarkdownTestCases(t *testing.T) {
 				`),
 			},
 			want: &Document{
-				Title: "Test Cases",
-				SQL:   "SELECT email FROM persons WHERE first_name=/*first_name*/'bob';",
+				Title:   "Test Cases",
+				SQL:     "SELECT email FROM persons WHERE first_name=/*first_name*/'bob';",
+				SQLLine: 4,
 				TestCases: []TestCase{
 					{
 						Name: "select test",
@@ -314,6 +322,7 @@ func TestParseMarkdownTestCases(t *testing.T) {
 						Expect: [][]string{
 							{"email"}, {"evanmacmans@example.com"},
 						},
+						Line: 9,
 					},
 				},
 			},
@@ -340,8 +349,9 @@ func TestParseMarkdownTestCases(t *testing.T) {
 				`),
 			},
 			want: &Document{
-				Title: "Test Cases",
-				SQL:   "DELETE FROM persons;",
+				Title:   "Test Cases",
+				SQL:     "DELETE FROM persons;",
+				SQLLine: 4,
 				TestCases: []TestCase{
 					{
 						Name:      "delete test",
@@ -349,6 +359,7 @@ func TestParseMarkdownTestCases(t *testing.T) {
 						Expect: [][]string{
 							{"count"}, {"1"},
 						},
+						Line: 9,
 					},
 				},
 			},
@@ -392,3 +403,23 @@ func TestParseMarkdownTestCases(t *testing.T) {
 		})
 	}
 }
+
+func TestParseMarkdown_SyntaxErrorPosition(t *testing.T) {
+	doc, err := ParseMarkdownString(testhelper.TrimIndent(t, `
+	# Search User Query
+
+	Search users by first name.
+
+	~~~sql
+	SELECT email, name FROM persons
+	WHERE /* IF first_name */first_name=/*first_name*/'bob'
+	~~~
+	`))
+	assert.NilError(t, err)
+	assert.Equal(t, 6, doc.SQLLine)
+
+	_, err = Compile(doc.SQL, WithSource("search.md", doc.SQLLine))
+	assert.Error(t, err, "search.md:7:7: can not parse: not found /* END */\n"+
+		"WHERE /* IF first_name */first_name=/*first_name*/'bob'\n"+
+		"      ^")
+}
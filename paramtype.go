@@ -0,0 +1,210 @@
+package twowaysql
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Decimal is a value of DecimalType parameters. It keeps the text to avoid losing precision.
+// It is bound as a string and compared as a number in conditions.
+type Decimal string
+
+// JSON is a value of JSONType parameters. It keeps the text to be bound as a string, because drivers don't accept
+// maps and slices. Conditions see the decoded value like map[string]any, so /* IF filter.name */ works.
+type JSON string
+
+var decimalPattern = regexp.MustCompile(`^[+-]?(\d+\.?\d*|\.\d+)([eE][+-]?\d+)?$`)
+
+// timestampLayouts are acceptable formats of TimestampType values
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// Convert parses value as the type. The result type is
+//
+//   - BoolType: bool
+//   - ByteType: uint8
+//   - FloatType: float64
+//   - IntType: int64
+//   - TextType: string
+//   - TimestampType and DateType: time.Time
+//   - DecimalType: Decimal
+//   - JSONType: JSON
+//   - arrays: []any of the element type
+//
+// Arrays are written in JSON/YAML like "[1, 2, 3]" or comma separated values like "1,2,3".
+// An empty value is nil (NULL) except for TextType, because other types have no empty value.
+// Use an empty array "[]" for arrays and a JSON string '""' for JSONType to pass empty values.
+func (p ParamType) Convert(value string) (any, error) {
+	if p.IsArray() {
+		return p.convertArray(value)
+	}
+	if value == "" && p != TextType {
+		return nil, nil
+	}
+	var result any
+	var err error
+	switch p {
+	case BoolType:
+		result, err = strconv.ParseBool(value)
+	case ByteType:
+		var v uint64
+		v, err = strconv.ParseUint(value, 10, 8)
+		result = uint8(v)
+	case FloatType:
+		result, err = strconv.ParseFloat(value, 64)
+	case IntType:
+		result, err = strconv.ParseInt(value, 10, 64)
+	case TextType:
+		result = value
+	case TimestampType:
+		for _, layout := range timestampLayouts {
+			if result, err = time.Parse(layout, value); err == nil {
+				break
+			}
+		}
+	case DateType:
+		result, err = time.Parse("2006-01-02", value)
+	case DecimalType:
+		if !decimalPattern.MatchString(value) {
+			err = fmt.Errorf("invalid decimal")
+		}
+		result = Decimal(value)
+	case JSONType:
+		if !json.Valid([]byte(value)) {
+			err = fmt.Errorf("invalid json")
+		}
+		result = JSON(value)
+	default:
+		return nil, fmt.Errorf("can't convert to %s", p)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("'%s' is not a valid %s value", value, p)
+	}
+	return result, nil
+}
+
+func (p ParamType) convertArray(value string) (any, error) {
+	var elems []any
+	if strings.HasPrefix(strings.TrimSpace(value), "[") {
+		if err := yaml.Unmarshal([]byte(value), &elems); err != nil {
+			return nil, fmt.Errorf("'%s' is not a valid %s value", value, p)
+		}
+	} else if strings.TrimSpace(value) != "" {
+		for _, e := range strings.Split(value, ",") {
+			elems = append(elems, strings.TrimSpace(e))
+		}
+	}
+	result := make([]any, 0, len(elems))
+	for _, e := range elems {
+		str, err := paramString(e)
+		if err != nil {
+			return nil, err
+		}
+		v, err := p.Elem().Convert(str)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, v)
+	}
+	return result, nil
+}
+
+// ConvertParams converts params to the types declared in the parameter table by ParamType.Convert.
+// Values other than strings like numbers and slices of JSON are encoded to JSON before the conversion.
+// Parameters not in the table are kept as they are. Errors don't have values of sensitive Params.
+func (d *Document) ConvertParams(params map[string]any) (map[string]any, error) {
+	types := make(map[string]ParamType, len(d.Params))
+	sensitive := make(map[string]bool)
+	for _, p := range d.Params {
+		types[p.Name] = p.Type
+		sensitive[p.Name] = p.Sensitive
+	}
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	result := make(map[string]any, len(params))
+	for _, name := range names {
+		value := params[name]
+		t, ok := types[name]
+		if !ok || value == nil {
+			result[name] = value
+			continue
+		}
+		str, err := paramString(value)
+		if err != nil {
+			return nil, fmt.Errorf("parameter '%s': %w", name, err)
+		}
+		if result[name], err = t.Convert(str); err != nil {
+			if sensitive[name] {
+				return nil, fmt.Errorf("parameter '%s': %s is not a valid %s value", name, Redacted, t)
+			}
+			return nil, fmt.Errorf("parameter '%s': %w", name, err)
+		}
+	}
+	return result, nil
+}
+
+// paramString returns a string as it is and encodes other values to JSON
+func paramString(v any) (string, error) {
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	b, err := json.Marshal(jsonValue(v))
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// jsonValue converts maps decoded by YAML to map[string]any to encode them to JSON
+func jsonValue(v any) any {
+	switch v := v.(type) {
+	case map[any]any:
+		result := make(map[string]any, len(v))
+		for k, e := range v {
+			result[fmt.Sprint(k)] = jsonValue(e)
+		}
+		return result
+	case []any:
+		result := make([]any, len(v))
+		for i, e := range v {
+			result[i] = jsonValue(e)
+		}
+		return result
+	}
+	return v
+}
+
+// yamlParam is a value of params in test cases. Lists and maps are kept in JSON to be converted by ParamType.Convert.
+type yamlParam string
+
+func (p *yamlParam) UnmarshalYAML(unmarshal func(any) error) error {
+	var s string
+	if err := unmarshal(&s); err == nil {
+		*p = yamlParam(s)
+		return nil
+	}
+	var v any
+	if err := unmarshal(&v); err != nil {
+		return err
+	}
+	s, err := paramString(v)
+	if err != nil {
+		return err
+	}
+	*p = yamlParam(s)
+	return nil
+}
@@ -0,0 +1,132 @@
+package twowaysql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/future-architect/go-twowaysql/private/testhelper"
+	"gotest.tools/v3/assert"
+)
+
+func TestParamType_Convert(t *testing.T) {
+	tests := []struct {
+		name    string
+		typ     ParamType
+		value   string
+		want    any
+		wantErr string
+	}{
+		{name: "bool", typ: BoolType, value: "true", want: true},
+		{name: "byte", typ: ByteType, value: "255", want: uint8(255)},
+		{name: "byte overflow", typ: ByteType, value: "256", wantErr: "'256' is not a valid byte value"},
+		{name: "float", typ: FloatType, value: "1.5", want: 1.5},
+		{name: "int", typ: IntType, value: "20", want: int64(20)},
+		{name: "invalid int", typ: IntType, value: "twenty", wantErr: "'twenty' is not a valid integer value"},
+		{name: "text", typ: TextType, value: "20", want: "20"},
+		{name: "empty text", typ: TextType, value: "", want: ""},
+		{name: "empty int", typ: IntType, value: "", want: nil},
+		{name: "timestamp", typ: TimestampType, value: "2022-09-13 10:30:15", want: time.Date(2022, 9, 13, 10, 30, 15, 0, time.UTC)},
+		{name: "timestamp RFC3339", typ: TimestampType, value: "2022-09-13T10:30:15+09:00", want: time.Date(2022, 9, 13, 10, 30, 15, 0, time.FixedZone("", 9*60*60))},
+		{name: "date", typ: DateType, value: "2022-09-13", want: time.Date(2022, 9, 13, 0, 0, 0, 0, time.UTC)},
+		{name: "invalid date", typ: DateType, value: "2022-09-13 10:30:15", wantErr: "'2022-09-13 10:30:15' is not a valid date value"},
+		{name: "decimal", typ: DecimalType, value: "12345678901234567890.123", want: Decimal("12345678901234567890.123")},
+		{name: "invalid decimal", typ: DecimalType, value: "1/3", wantErr: "'1/3' is not a valid decimal value"},
+		{name: "json", typ: JSONType, value: `{"name": "bob", "age": 20}`, want: JSON(`{"name": "bob", "age": 20}`)},
+		{name: "invalid json", typ: JSONType, value: `{name: bob}`, wantErr: "'{name: bob}' is not a valid json value"},
+		{name: "empty json", typ: JSONType, value: "", want: nil},
+		{name: "int array", typ: ArrayOf(IntType), value: "[1, 2, 3]", want: []any{int64(1), int64(2), int64(3)}},
+		{name: "comma separated array", typ: ArrayOf(TextType), value: "a, b", want: []any{"a", "b"}},
+		{name: "empty array", typ: ArrayOf(IntType), value: "", want: []any{}},
+		{name: "invalid array element", typ: ArrayOf(IntType), value: "[1, a]", wantErr: "'a' is not a valid integer value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.typ.Convert(tt.value)
+			if tt.wantErr != "" {
+				assert.Error(t, err, tt.wantErr)
+				return
+			}
+			assert.NilError(t, err)
+			assert.DeepEqual(t, tt.want, got)
+		})
+	}
+}
+
+func TestLookupParamType(t *testing.T) {
+	typ, ok := lookupParamType("Integer[]")
+	assert.Assert(t, ok)
+	assert.Equal(t, ArrayOf(IntType), typ)
+	assert.Assert(t, typ.IsArray())
+	assert.Equal(t, IntType, typ.Elem())
+	assert.Equal(t, "integer[]", typ.String())
+
+	_, ok = lookupParamType("unknown[]")
+	assert.Assert(t, !ok)
+}
+
+func TestDocument_ConvertParams(t *testing.T) {
+	doc, err := ParseMarkdownString(testhelper.TrimIndent(t, `
+	# Search Users
+
+	~~~sql
+	SELECT * FROM persons WHERE /* IF age > 20 */age > /*age*/20 AND /* END */ dept_no IN /*dept_nos*/(1)
+	~~~
+
+	## Parameters
+
+	| Name     | Type  | Description |
+	|----------|-------|-------------|
+	| age      | int   | age         |
+	| dept_nos | int[] | departments |
+
+	## Test
+
+	### Case: search
+
+	~~~yaml
+	params: { age: 30, dept_nos: [10, 11], name: bob }
+	expect: []
+	~~~
+	`))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, map[string]string{"age": "30", "dept_nos": "[10,11]", "name": "bob"}, doc.TestCases[0].Params)
+
+	params := make(map[string]any)
+	for k, v := range doc.TestCases[0].Params {
+		params[k] = v
+	}
+	got, err := doc.ConvertParams(params)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, map[string]any{"age": int64(30), "dept_nos": []any{int64(10), int64(11)}, "name": "bob"}, got)
+
+	query, binds, err := Eval(doc.SQL, got, WithCleanup())
+	assert.NilError(t, err)
+	assert.Equal(t, "SELECT * FROM persons WHERE age > ?/*age*/ AND dept_no IN (?, ?)/*dept_nos*/", query)
+	assert.DeepEqual(t, []any{int64(30), int64(10), int64(11)}, binds)
+
+	// numbers of CLI parameters are converted as well
+	got, err = doc.ConvertParams(map[string]any{"age": float64(30), "dept_nos": []any{float64(10)}})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, map[string]any{"age": int64(30), "dept_nos": []any{int64(10)}}, got)
+
+	_, err = doc.ConvertParams(map[string]any{"age": "thirty"})
+	assert.Error(t, err, "parameter 'age': 'thirty' is not a valid integer value")
+}
+
+func TestDecimal_Condition(t *testing.T) {
+	query, _, err := Eval("SELECT * FROM items /* IF price > 100 */WHERE price > /*price*/100 /* END */", map[string]any{"price": Decimal("100.5")})
+	assert.NilError(t, err)
+	assert.Equal(t, "SELECT * FROM items WHERE price > ?/*price*/", query)
+}
+
+func TestJSON_Condition(t *testing.T) {
+	filter, err := JSONType.Convert(`{"name": "bob", "tags": ["a", "b"]}`)
+	assert.NilError(t, err)
+	params := map[string]any{"filter": filter}
+	for _, engine := range []ConditionEngine{ExprEngine{}, OttoEngine{}} {
+		query, binds, err := Eval("SELECT * FROM users WHERE attrs @> /*filter*/'{}' /* IF filter.name == 'bob' && filter.tags.length == 2 */AND name = 'bob' /* END */", params, WithConditionEngine(engine))
+		assert.NilError(t, err)
+		assert.Equal(t, "SELECT * FROM users WHERE attrs @> ?/*filter*/ AND name = 'bob'", query)
+		assert.DeepEqual(t, []any{JSON(`{"name": "bob", "tags": ["a", "b"]}`)}, binds)
+	}
+}
@@ -1,193 +1,253 @@
 package twowaysql
 
 import (
+	"context"
+	"errors"
 	"fmt"
-
-	"github.com/robertkrimen/otto"
+	"reflect"
+	"strconv"
+	"strings"
 )
 
-type tokenGroup struct {
-	tokens []token
+// 抽象構文木からトークン列を生成
+// SQLStmt, Bindは左部分木を辿る
+// Rawは許可された値をSQLStmtとして埋め込み、左部分木を辿る
+// IF, ELIF, ELSEは条件に応じて左部分木(本体)か右部分木(次の分岐)を選び、
+// FORは要素ごとに左部分木(本体)を繰り返し、
+// 最後にENDの左部分木(後続の文)を辿る
+func (t *tree) parse(params map[string]interface{}) ([]token, error) {
+	return t.parseContext(context.Background(), params, newOptions(nil), nil)
 }
 
-func parseCondition(tokens []token, mapParams map[string]interface{}) ([]token, error) {
-	var tokenGroups []tokenGroup
-	var tmpTokens []token
-	var idx int
-	for idx < len(tokens) {
-		if tokens[idx].kind != tkIf {
-			tmpTokens = append(tmpTokens, tokens[idx])
-			idx++
-			continue
-		}
-		tokenGroups = append(tokenGroups, tokenGroup{tokens: tmpTokens})
-		tmpTokens = []token{}
+func (t *tree) parseContext(ctx context.Context, params map[string]interface{}, o *options, sensitive *sensitiveSet) ([]token, error) {
+	tokens := []token{}
+	sc := &scope{params: params, converters: o.converters, marks: o.format == FormatLines, sensitive: sensitive}
+	if err := genInner(ctx, t, sc, &tokens); err != nil {
+		return []token{}, err
+	}
+	return tokens, nil
+}
+
+// scope holds parameters that are visible from the current node.
+// aliases maps loop variables of FOR to the paths of the current elements (e.g. item -> items.0)
+// so that bind values in loops can be resolved from the original parameters later.
+type scope struct {
+	params     map[string]interface{}
+	aliases    map[string]string
+	converters converters
+	// marks is true to insert dropMark where directives are removed for FormatLines
+	marks bool
+	// params converted for conditions. it is created when it is needed
+	condParams map[string]interface{}
+	// sensitive parameters not to show their values in errors
+	sensitive *sensitiveSet
+}
 
-		iftokenGroup, err := parseIftokenGroup(tokens, &idx, mapParams)
+func (s *scope) conditionParams() (map[string]interface{}, error) {
+	if s.condParams == nil {
+		p, err := s.converters.conditionParams(s.params)
 		if err != nil {
 			return nil, err
 		}
-		tokenGroups = append(tokenGroups, tokenGroup{tokens: iftokenGroup})
+		s.condParams = p
 	}
-	if len(tmpTokens) != 0 {
-		tokenGroups = append(tokenGroups, tokenGroup{tokens: tmpTokens})
+	return s.condParams, nil
+}
+
+// set sets a parameter. It is converted for conditions if converted parameters are already created,
+// so that other parameters are not converted again for each element of loops.
+func (s *scope) set(name string, value interface{}) error {
+	s.params[name] = value
+	if s.condParams != nil {
+		converted, err := s.converters.conditionValue(value)
+		if err != nil {
+			return err
+		}
+		s.condParams[name] = converted
 	}
+	return nil
+}
 
-	var generatedTokens []token
-	for _, tg := range tokenGroups {
-		generatedTokens = append(generatedTokens, tg.tokens...)
+func (s *scope) path(name string) string {
+	root, rest, found := strings.Cut(name, ".")
+	if p, ok := s.aliases[root]; ok {
+		if found {
+			return p + "." + rest
+		}
+		return p
 	}
+	return name
+}
 
-	if generatedTokens[len(generatedTokens)-1].kind != tkEndOfProgram {
-		// 末尾に EndOfProgram を追加
-		generatedTokens = append(generatedTokens, token{kind: tkEndOfProgram})
+func (s *scope) child() *scope {
+	c := &scope{
+		params:     make(map[string]interface{}, len(s.params)+1),
+		aliases:    make(map[string]string, len(s.aliases)+1),
+		converters: s.converters,
+		marks:      s.marks,
+		sensitive:  s.sensitive,
 	}
-	// 構文エラーチェックのため生成 token 全体でも解析を行う
-	_, err := ast(generatedTokens)
-	if err != nil {
-		return nil, err
+	for k, v := range s.params {
+		c.params[k] = v
+	}
+	for k, v := range s.aliases {
+		c.aliases[k] = v
 	}
+	if s.condParams != nil {
+		c.condParams = make(map[string]interface{}, len(s.condParams)+1)
+		for k, v := range s.condParams {
+			c.condParams[k] = v
+		}
+	}
+	return c
+}
 
-	return generatedTokens, nil
+// mark inserts dropMark where a directive is removed
+func (s *scope) mark(dest *[]token) {
+	if s.marks {
+		*dest = append(*dest, token{
+			kind: tkSQLStmt,
+			str:  dropMark,
+		})
+	}
 }
 
-func parseIftokenGroup(tokens []token, idx *int, mapParams map[string]interface{}) ([]token, error) {
-	tmpTokens := []token{}
-	iftokenGroup := []token{tokens[*idx]} // IF
-	*idx++
-	for {
-		if *idx >= len(tokens) {
-			return nil, fmt.Errorf("can not parse: not found /* END */")
-		}
-		// nest IF
-		if tokens[*idx].kind == tkIf {
-			nestTokens, err := parseIftokenGroup(tokens, idx, mapParams)
+func genInner(ctx context.Context, node *tree, sc *scope, dest *[]token) error {
+	for node != nil {
+		switch node.Kind {
+		case ndSQLStmt:
+			*dest = append(*dest, *node.Token)
+			node = node.Left
+		case ndBind:
+			tok := *node.Token
+			tok.value = sc.path(tok.value)
+			*dest = append(*dest, tok)
+			node = node.Left
+		case ndRaw:
+			str, err := rawValue(node, sc.params, sc.sensitive.has(sc.path(node.Token.value)))
 			if err != nil {
-				return nil, err
+				return err
 			}
-			tmpTokens = append(tmpTokens, nestTokens...)
-			// idx は parseIftokens 内で進んでいるためプラスしない
-			continue
-		}
-		// ELSE/ELIF
-		if tokens[*idx].kind == tkElse || tokens[*idx].kind == tkElif {
-			nestTokens, err := parseCondition(tmpTokens, mapParams)
+			*dest = append(*dest, token{
+				kind: tkSQLStmt,
+				str:  str,
+			})
+			node = node.Left
+		case ndIf, ndElif:
+			sc.mark(dest)
+			if node.Condition == nil {
+				return fmt.Errorf("condition is not compiled: %s", node.Token.condition)
+			}
+			if err := ctx.Err(); err != nil {
+				return &EvalCanceledError{Condition: node.Token.condition, Err: err}
+			}
+			params, err := sc.conditionParams()
 			if err != nil {
-				return nil, err
+				return err
 			}
-			// ネストしたブロックを解析する際に末尾に EndOfProgram が付与されるため除去
-			if len(nestTokens) > 0 && nestTokens[len(nestTokens)-1].kind == tkEndOfProgram {
-				nestTokens = nestTokens[0 : len(nestTokens)-1]
+			truth, err := node.Condition.Eval(ctx, params)
+			if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+				return &EvalCanceledError{Condition: node.Token.condition, Err: err}
+			} else if err != nil {
+				return err
 			}
-			tmpTokens = nestTokens
-			tmpTokens = append(tmpTokens, tokens[*idx]) // ELSE/ELIF を追加
-			iftokenGroup = append(iftokenGroup, tmpTokens...)
-			tmpTokens = []token{}
-			*idx++
-			continue
-		}
-
-		if tokens[*idx].kind != tkEnd {
-			tmpTokens = append(tmpTokens, tokens[*idx])
-			*idx++
-			continue
+			if !truth {
+				node = node.Right
+				continue
+			}
+			if err := genInner(ctx, node.Left, sc, dest); err != nil {
+				return err
+			}
+			sc.mark(dest)
+			node = endOf(node).Left
+		case ndElse:
+			sc.mark(dest)
+			if err := genInner(ctx, node.Left, sc, dest); err != nil {
+				return err
+			}
+			sc.mark(dest)
+			node = endOf(node).Left
+		case ndFor:
+			sc.mark(dest)
+			if err := genLoop(ctx, node, sc, dest); err != nil {
+				return err
+			}
+			sc.mark(dest)
+			node = endOf(node).Left
+		case ndEnd:
+			sc.mark(dest)
+			node = node.Left
+		default:
+			return nil
 		}
-
-		// END
-		iftokenGroup = append(iftokenGroup, tmpTokens...) // IF ブロック内
-		iftokenGroup = append(iftokenGroup, tokens[*idx]) // END
-		tmpTokens = []token{}
-		*idx++
-		break
 	}
-
-	// IF ブロックのみで解析するため、末尾に EndOfProgram を追加
-	iftokenGroup = append(iftokenGroup, token{kind: tkEndOfProgram})
-	tree, err := ast(iftokenGroup)
-	if err != nil {
-		return nil, err
-	}
-	generatedTokens, err := tree.parse(mapParams)
-	if err != nil {
-		return nil, err
-	}
-	if len(generatedTokens) > 0 && generatedTokens[len(generatedTokens)-1].kind == tkEndOfProgram {
-		// 末尾の EndOfProgram を除去
-		generatedTokens = generatedTokens[0 : len(generatedTokens)-1]
-	}
-
-	return generatedTokens, nil
-}
-
-// 抽象構文木からトークン列を生成
-// 左部分木、右部分木と辿る
-// 現状右部分木を持つのはif, elif, elseだけ?
-func (t *tree) parse(params map[string]interface{}) ([]token, error) {
-	return genInner(t, params)
+	return nil
 }
 
-func genInner(node *tree, params map[string]interface{}) ([]token, error) {
-	if node == nil {
-		return []token{}, nil
-	}
-
-	// 行きがけ
-
-	// 左部分木に行く
-	leftStr, err := genInner(node.Left, params)
-	if err != nil {
-		return []token{}, err
-	}
-
-	// 左部分木から戻ってきた
-
-	// 右部分木に行く
-	rightStr, err := genInner(node.Right, params)
+// /* FOR item IN items */ body /* SEPARATOR sep */ sepBody /* END */
+// body is repeated for each element. "sep" and sepBody are inserted between elements.
+func genLoop(ctx context.Context, node *tree, sc *scope, dest *[]token) error {
+	variable := node.Token.value
+	collectionName := node.Token.condition
+	collection, err := lookupParam(sc.params, collectionName)
 	if err != nil {
-		return []token{}, err
+		return err
+	}
+	collection = normalizeValue(collection)
+	if collection == nil {
+		return nil
+	}
+	rv := reflect.ValueOf(collection)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return fmt.Errorf("FOR requires slice or array, but %s is %T", collectionName, collection)
+	}
+	var separator *tree
+	if node.Right.Kind == ndSeparator {
+		separator = node.Right
+	}
+	collectionPath := sc.path(collectionName)
+	c := sc.child()
+	// reuse elements converted for conditions not to convert them again
+	var converted reflect.Value
+	if c.condParams != nil {
+		if v, err := lookupParam(c.condParams, collectionName); err == nil {
+			converted = reflect.ValueOf(normalizeValue(v))
+			if (converted.Kind() != reflect.Slice && converted.Kind() != reflect.Array) || converted.Len() != rv.Len() {
+				converted = reflect.Value{}
+			}
+		}
 	}
-
-	// 右部分木から戻ってきた
-	// 何を返すか
-	// 基本的に左部分木
-	// If Elifの場合は条件次第
-	switch kind := node.Kind; kind {
-	case ndSQLStmt, ndBind:
-		// めちゃめちゃ実行効率悪い気が...
-		return append([]token{*node.Token}, leftStr...), nil
-	case ndIf, ndElif:
-		truth, err := evalCondition(node.Token.condition, params)
-		if err != nil {
-			return []token{}, err
+	for i := 0; i < rv.Len(); i++ {
+		if i > 0 && separator != nil {
+			if separator.Token.value != "" {
+				*dest = append(*dest, token{
+					kind: tkSQLStmt,
+					str:  " " + separator.Token.value + " ",
+				})
+			}
+			if err := genInner(ctx, separator.Left, c, dest); err != nil {
+				return err
+			}
 		}
-		if truth {
-			return leftStr, nil
+		c.aliases[variable] = collectionPath + "." + strconv.Itoa(i)
+		if converted.IsValid() {
+			c.params[variable] = rv.Index(i).Interface()
+			c.condParams[variable] = converted.Index(i).Interface()
+		} else if err := c.set(variable, rv.Index(i).Interface()); err != nil {
+			return err
 		}
-		return rightStr, nil
-	default:
-		return leftStr, nil
-	}
-}
-
-// /* If ... */ /* Elif ... */の条件を評価する
-func evalCondition(condition string, params map[string]interface{}) (bool, error) {
-	vm := otto.New()
-	for key, value := range params {
-		err := vm.Set(key, value)
-		if err != nil {
-			return false, err
+		c.mark(dest)
+		if err := genInner(ctx, node.Left, c, dest); err != nil {
+			return err
 		}
 	}
+	return nil
+}
 
-	result, err := vm.Run(condition)
-	if err != nil {
-		return false, err
-	}
-
-	truth, err := result.ToBoolean()
-	if err != nil {
-		return false, err
+// IF/ELIF/ELSE/FORノードから右部分木を辿り、対応するENDノードを返す
+func endOf(node *tree) *tree {
+	for node.Kind != ndEnd {
+		node = node.Right
 	}
-
-	return truth, nil
+	return node
 }
@@ -88,12 +88,19 @@ func TestParse(t *testing.T) {
 					kind: tkSQLStmt,
 					str:  " AND id=3 ",
 				},
+				{
+					kind: tkSQLStmt,
+					str:  " ",
+				},
 			},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.input.compileConditions(ExprEngine{}); err != nil {
+				t.Fatal(err)
+			}
 			if got, err := tt.input.parse(map[string]interface{}{}); err != nil || !tokensEqual(tt.want, got) {
 				if err != nil {
 					t.Error(err)
@@ -0,0 +1,138 @@
+package twowaysql
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+)
+
+// Placeholder is a style of placeholders in evaluated queries.
+type Placeholder int
+
+const (
+	// Question emits ? (MySQL, SQLite). Default.
+	// Twowaysql converts it into the style of the driver by sqlx.Rebind.
+	Question Placeholder = iota
+	// Dollar emits $1, $2... (PostgreSQL)
+	Dollar
+	// Named emits :name (Oracle). Bind values are returned as sql.NamedArg.
+	Named
+	// AtP emits @p1, @p2... (SQL Server)
+	AtP
+)
+
+func (p Placeholder) String() string {
+	switch p {
+	case Question:
+		return "question"
+	case Dollar:
+		return "dollar"
+	case Named:
+		return "named"
+	case AtP:
+		return "at"
+	default:
+		return "unknown"
+	}
+}
+
+// WithPlaceholder sets the style of placeholders in evaluated queries.
+// Dollar, Named and AtP reuse the same placeholder when a parameter is referenced more than once.
+// Twowaysql skips sqlx.Rebind for templates compiled with other than Question.
+func WithPlaceholder(p Placeholder) Option {
+	return func(o *options) {
+		o.placeholder = p
+	}
+}
+
+// binder collects bind values and returns placeholders for them
+type binder struct {
+	style  Placeholder
+	params []interface{}
+	// path -> placeholder
+	placeholders map[string]string
+	// named placeholder -> path
+	names map[string]string
+	// sensitive parameters. redacted is nil until a sensitive value is bound
+	sensitive *sensitiveSet
+	redacted  []interface{}
+	// ownSensitive is true after sensitive is copied to be marked
+	ownSensitive bool
+}
+
+func newBinder(style Placeholder, capacity int) *binder {
+	return &binder{
+		style:        style,
+		params:       make([]interface{}, 0, capacity),
+		placeholders: make(map[string]string),
+		names:        make(map[string]string),
+	}
+}
+
+// bind adds value of path and returns placeholder
+func (b *binder) bind(path string, value interface{}) string {
+	if b.style == Question {
+		b.add(path, value)
+		return "?"
+	}
+	if p, ok := b.placeholders[path]; ok {
+		return p
+	}
+	var p string
+	switch b.style {
+	case Dollar:
+		b.add(path, value)
+		p = "$" + strconv.Itoa(len(b.params))
+	case AtP:
+		b.add(path, value)
+		p = "@p" + strconv.Itoa(len(b.params))
+	case Named:
+		name := b.uniqueName(path)
+		b.add(path, sql.Named(name, value))
+		p = ":" + name
+	}
+	b.placeholders[path] = p
+	return p
+}
+
+// add appends the bind value and the redacted value if path is sensitive
+func (b *binder) add(path string, value interface{}) {
+	b.params = append(b.params, value)
+	if b.sensitive.has(path) {
+		if b.redacted == nil {
+			b.redacted = append(make([]interface{}, 0, cap(b.params)), b.params[:len(b.params)-1]...)
+		}
+		b.redacted = append(b.redacted, redactArg(value))
+	} else if b.redacted != nil {
+		b.redacted = append(b.redacted, value)
+	}
+}
+
+// markSensitive marks path as sensitive
+func (b *binder) markSensitive(path string) {
+	// sensitive may be shared with options and other evaluations
+	if !b.ownSensitive {
+		b.sensitive = b.sensitive.clone()
+		b.ownSensitive = true
+	}
+	b.sensitive.add(path)
+}
+
+// uniqueName converts path like "items.0.name" into identifier "items_0_name"
+func (b *binder) uniqueName(path string) string {
+	base := strings.Map(func(r rune) rune {
+		if r == '_' || ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9') {
+			return r
+		}
+		return '_'
+	}, path)
+	name := base
+	for i := 2; ; i++ {
+		if p, ok := b.names[name]; !ok || p == path {
+			break
+		}
+		name = base + "_" + strconv.Itoa(i)
+	}
+	b.names[name] = path
+	return name
+}
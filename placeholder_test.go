@@ -0,0 +1,117 @@
+package twowaysql
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func TestEval_Placeholder(t *testing.T) {
+	type Filter struct {
+		Value string `twowaysql:"value"`
+	}
+
+	const query = `SELECT * FROM person WHERE (first_name = /*name*/'Jeff' OR last_name = /*name*/'Dean') AND dept_no IN /*deptNos*/(1, 2) AND employee_no < /*maxEmpNo*/1000`
+	params := map[string]interface{}{
+		"name":     "Jeff",
+		"deptNos":  []int{10, 11},
+		"maxEmpNo": 2000,
+	}
+
+	tests := []struct {
+		name        string
+		input       string
+		inputParams map[string]interface{}
+		placeholder Placeholder
+		wantQuery   string
+		wantParams  []interface{}
+	}{
+		{
+			name:        "question",
+			input:       query,
+			inputParams: params,
+			placeholder: Question,
+			wantQuery:   `SELECT * FROM person WHERE (first_name = ?/*name*/ OR last_name = ?/*name*/) AND dept_no IN (?, ?)/*deptNos*/ AND employee_no < ?/*maxEmpNo*/`,
+			wantParams:  []interface{}{"Jeff", "Jeff", 10, 11, 2000},
+		},
+		{
+			name:        "dollar",
+			input:       query,
+			inputParams: params,
+			placeholder: Dollar,
+			wantQuery:   `SELECT * FROM person WHERE (first_name = $1/*name*/ OR last_name = $1/*name*/) AND dept_no IN ($2, $3)/*deptNos*/ AND employee_no < $4/*maxEmpNo*/`,
+			wantParams:  []interface{}{"Jeff", 10, 11, 2000},
+		},
+		{
+			name:        "at",
+			input:       query,
+			inputParams: params,
+			placeholder: AtP,
+			wantQuery:   `SELECT * FROM person WHERE (first_name = @p1/*name*/ OR last_name = @p1/*name*/) AND dept_no IN (@p2, @p3)/*deptNos*/ AND employee_no < @p4/*maxEmpNo*/`,
+			wantParams:  []interface{}{"Jeff", 10, 11, 2000},
+		},
+		{
+			name:        "named",
+			input:       query,
+			inputParams: params,
+			placeholder: Named,
+			wantQuery:   `SELECT * FROM person WHERE (first_name = :name/*name*/ OR last_name = :name/*name*/) AND dept_no IN (:deptNos_0, :deptNos_1)/*deptNos*/ AND employee_no < :maxEmpNo/*maxEmpNo*/`,
+			wantParams:  []interface{}{sql.Named("name", "Jeff"), sql.Named("deptNos_0", 10), sql.Named("deptNos_1", 11), sql.Named("maxEmpNo", 2000)},
+		},
+		{
+			name:  "table",
+			input: `INSERT INTO person (first_name, last_name) VALUES /*people*/('Jeff', 'Dean')`,
+			inputParams: map[string]interface{}{
+				"people": [][]interface{}{{"Jeff", "Dean"}, {"Dan", "Conner"}},
+			},
+			placeholder: Dollar,
+			wantQuery:   `INSERT INTO person (first_name, last_name) VALUES (($1, $2), ($3, $4))/*people*/`,
+			wantParams:  []interface{}{"Jeff", "Dean", "Dan", "Conner"},
+		},
+		{
+			name:  "for",
+			input: `SELECT * FROM person WHERE /* FOR f IN filters */ first_name = /*f.value*/'Jeff' OR last_name = /*f.value*/'Dean' /* SEPARATOR OR */ /* END */`,
+			inputParams: map[string]interface{}{
+				"filters": []Filter{{Value: "Jeff"}, {Value: "Dan"}},
+			},
+			placeholder: Named,
+			wantQuery:   `SELECT * FROM person WHERE first_name = :filters_0_value/*f.value*/ OR last_name = :filters_0_value/*f.value*/ OR first_name = :filters_1_value/*f.value*/ OR last_name = :filters_1_value/*f.value*/`,
+			wantParams:  []interface{}{sql.Named("filters_0_value", "Jeff"), sql.Named("filters_1_value", "Dan")},
+		},
+		{
+			name:  "name conflict",
+			input: `SELECT * FROM person WHERE first_name = /*user.name*/'Jeff' AND last_name = /*user_name*/'Dean'`,
+			inputParams: map[string]interface{}{
+				"user":      map[string]interface{}{"name": "Jeff"},
+				"user_name": "Dean",
+			},
+			placeholder: Named,
+			wantQuery:   `SELECT * FROM person WHERE first_name = :user_name/*user.name*/ AND last_name = :user_name_2/*user_name*/`,
+			wantParams:  []interface{}{sql.Named("user_name", "Jeff"), sql.Named("user_name_2", "Dean")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, params, err := Eval(tt.input, tt.inputParams, WithPlaceholder(tt.placeholder))
+			assert.NilError(t, err)
+			assert.Check(t, cmp.DeepEqual(tt.wantParams, params, cmpopts.IgnoreUnexported(sql.NamedArg{})))
+			assert.Check(t, cmp.DeepEqual(tt.wantQuery, query))
+		})
+	}
+}
+
+func TestTemplate_Rebind(t *testing.T) {
+	rebind := func(q string) string {
+		return "rebound: " + q
+	}
+
+	tmpl := MustCompile(`SELECT * FROM person WHERE first_name = /*name*/'Jeff'`)
+	assert.Equal(t, "rebound: query", tmpl.rebind("query", rebind))
+
+	tmpl = MustCompile(`SELECT * FROM person WHERE first_name = /*name*/'Jeff'`, WithPlaceholder(Dollar))
+	assert.Equal(t, "query", tmpl.rebind("query", rebind))
+}
@@ -0,0 +1,55 @@
+package twowaysql
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// compileRaws sets allowed values to /*$name*/ nodes.
+// A raw substitution without allowed values is an error because the value would be embedded unchecked.
+func (t *tree) compileRaws(allowed map[string][]string) error {
+	if t == nil {
+		return nil
+	}
+	if t.Kind == ndRaw {
+		values, ok := allowed[t.Token.value]
+		if !ok || len(values) == 0 {
+			return fmt.Errorf("no allowed values are registered for raw parameter: %s", t.Token.value)
+		}
+		t.Allowed = make(map[string]bool, len(values))
+		for _, v := range values {
+			t.Allowed[v] = true
+		}
+	}
+	if err := t.Left.compileRaws(allowed); err != nil {
+		return err
+	}
+	return t.Right.compileRaws(allowed)
+}
+
+// rawValue returns the value of /*$name*/ that is embedded into the query.
+// The value is shown as Redacted in the error if sensitive is true.
+func rawValue(node *tree, params map[string]interface{}, sensitive bool) (string, error) {
+	name := node.Token.value
+	if node.Allowed == nil {
+		return "", fmt.Errorf("raw parameter is not compiled: %s", name)
+	}
+	v, err := lookupParam(params, name)
+	if err != nil {
+		return "", err
+	}
+	rv := reflect.ValueOf(normalizeValue(v))
+	if rv.Kind() != reflect.String {
+		return "", fmt.Errorf("raw parameter %s must be string, but %T", name, v)
+	}
+	str := rv.String()
+	if !node.Allowed[str] {
+		shown := strconv.Quote(str)
+		if sensitive {
+			shown = Redacted
+		}
+		return "", fmt.Errorf("value %s is not allowed for raw parameter %s", shown, name)
+	}
+	return str, nil
+}
@@ -0,0 +1,171 @@
+package twowaysql
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func TestEval_Raw(t *testing.T) {
+	type SortColumn string
+	type Sort struct {
+		Column string `twowaysql:"column"`
+		Order  string `twowaysql:"order"`
+	}
+
+	opts := []Option{
+		WithAllowedValues("sortColumn", "employee_no", "first_name"),
+		WithAllowedValues("sortOrder", "ASC", "DESC"),
+		WithAllowedValues("sort.column", "employee_no", "first_name"),
+		WithAllowedValues("sort.order", "ASC", "DESC"),
+		WithAllowedValues("s.column", "employee_no", "first_name"),
+	}
+
+	tests := []struct {
+		name        string
+		input       string
+		inputParams map[string]interface{}
+		wantQuery   string
+		wantParams  []interface{}
+	}{
+		{
+			name:  "raw",
+			input: `SELECT * FROM person WHERE dept_no = /*deptNo*/1 ORDER BY /*$sortColumn*/employee_no /*$sortOrder*/ASC`,
+			inputParams: map[string]interface{}{
+				"deptNo":     10,
+				"sortColumn": "first_name",
+				"sortOrder":  "DESC",
+			},
+			wantQuery:  `SELECT * FROM person WHERE dept_no = ?/*deptNo*/ ORDER BY first_name DESC`,
+			wantParams: []interface{}{10},
+		},
+		{
+			name:  "string type",
+			input: `SELECT * FROM person ORDER BY /*$sortColumn*/employee_no`,
+			inputParams: map[string]interface{}{
+				"sortColumn": SortColumn("first_name"),
+			},
+			wantQuery:  `SELECT * FROM person ORDER BY first_name`,
+			wantParams: []interface{}{},
+		},
+		{
+			name:  "dotted path",
+			input: `SELECT * FROM person ORDER BY /*$sort.column*/employee_no /*$sort.order*/ASC`,
+			inputParams: map[string]interface{}{
+				"sort": Sort{Column: "first_name", Order: "ASC"},
+			},
+			wantQuery:  `SELECT * FROM person ORDER BY first_name ASC`,
+			wantParams: []interface{}{},
+		},
+		{
+			name:  "raw in for",
+			input: `SELECT * FROM person ORDER BY /* FOR s IN sorts */ /*$s.column*/employee_no /* SEPARATOR , */ /* END */`,
+			inputParams: map[string]interface{}{
+				"sorts": []Sort{{Column: "first_name"}, {Column: "employee_no"}},
+			},
+			wantQuery:  `SELECT * FROM person ORDER BY first_name , employee_no`,
+			wantParams: []interface{}{},
+		},
+		{
+			name:  "raw in if",
+			input: `SELECT * FROM person /* IF sortColumn */ ORDER BY /*$sortColumn*/employee_no /* END */`,
+			inputParams: map[string]interface{}{
+				"sortColumn": "",
+			},
+			wantQuery:  `SELECT * FROM person`,
+			wantParams: []interface{}{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, params, err := Eval(tt.input, tt.inputParams, opts...)
+			assert.NilError(t, err)
+			assert.Check(t, cmp.DeepEqual(tt.wantParams, params))
+			assert.Check(t, cmp.DeepEqual(tt.wantQuery, query))
+		})
+	}
+}
+
+func TestEval_RawShouldReturnError(t *testing.T) {
+	opts := []Option{
+		WithAllowedValues("sortColumn", "employee_no", "first_name"),
+	}
+
+	tests := []struct {
+		name        string
+		input       string
+		inputParams map[string]interface{}
+		opts        []Option
+		wantError   string
+	}{
+		{
+			name:  "no allowed values",
+			input: `SELECT * FROM person ORDER BY /*$sortColumn*/employee_no`,
+			inputParams: map[string]interface{}{
+				"sortColumn": "first_name",
+			},
+			wantError: "no allowed values are registered for raw parameter: sortColumn",
+		},
+		{
+			name:  "not allowed",
+			input: `SELECT * FROM person ORDER BY /*$sortColumn*/employee_no`,
+			inputParams: map[string]interface{}{
+				"sortColumn": "first_name; DROP TABLE person",
+			},
+			opts:      opts,
+			wantError: `value "first_name; DROP TABLE person" is not allowed for raw parameter sortColumn`,
+		},
+		{
+			name:  "sensitive value is not shown",
+			input: `SELECT * FROM person ORDER BY /*$sortColumn*/employee_no`,
+			inputParams: map[string]interface{}{
+				"sortColumn": "secret",
+			},
+			opts:      append([]Option{WithSensitive("sortColumn")}, opts...),
+			wantError: `value [REDACTED] is not allowed for raw parameter sortColumn`,
+		},
+		{
+			name:        "no parameter",
+			input:       `SELECT * FROM person ORDER BY /*$sortColumn*/employee_no`,
+			inputParams: map[string]interface{}{},
+			opts:        opts,
+			wantError:   "no parameter that matches the bind value: sortColumn",
+		},
+		{
+			name:  "not string",
+			input: `SELECT * FROM person ORDER BY /*$sortColumn*/employee_no`,
+			inputParams: map[string]interface{}{
+				"sortColumn": 1,
+			},
+			opts:      opts,
+			wantError: "raw parameter sortColumn must be string, but int",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := Eval(tt.input, tt.inputParams, tt.opts...)
+			assert.Error(t, err, tt.wantError)
+		})
+	}
+}
+
+func TestDocument_Options(t *testing.T) {
+	doc, err := ParseMarkdownString("# Sorted Persons\n\n" +
+		"~~~sql\nSELECT * FROM persons ORDER BY /*$sort*/employee_no;\n~~~\n\n" +
+		"## Parameters\n\n" +
+		"| Name | Type   | AllowedValues            | Description |\n" +
+		"|------|--------|--------------------------|-------------|\n" +
+		"| sort | string | employee_no, `last_name` | sort key    |\n")
+	assert.NilError(t, err)
+	assert.Check(t, cmp.DeepEqual([]string{"employee_no", "last_name"}, doc.Params[0].AllowedValues))
+
+	query, _, err := Eval(doc.SQL, map[string]interface{}{"sort": "last_name"}, doc.Options()...)
+	assert.NilError(t, err)
+	assert.Equal(t, `SELECT * FROM persons ORDER BY last_name;`, query)
+
+	_, _, err = Eval(doc.SQL, map[string]interface{}{"sort": "first_name"}, doc.Options()...)
+	assert.Error(t, err, `value "first_name" is not allowed for raw parameter sort`)
+}
@@ -0,0 +1,382 @@
+package twowaysql
+
+import (
+	"database/sql"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Redacted is shown instead of values of sensitive parameters in hooks, errors and outputs of the CLI.
+const Redacted = "[REDACTED]"
+
+// WithSensitive marks parameters as sensitive like passwords and personal data.
+// Their bind values are replaced with Redacted in QueryEvent.Args of hooks, Template.EvalRedacted and errors of queries.
+// Names can use the wildcard "*" for any element of slices and maps like "filters.*.email".
+// Fields of params structs can also be marked by the tag option like `twowaysql:"email,sensitive"`,
+// and parameters of Markdown documents by "(sensitive)" in the description.
+func WithSensitive(names ...string) Option {
+	return func(o *options) {
+		if o.sensitive == nil {
+			o.sensitive = &sensitiveSet{}
+		}
+		for _, name := range names {
+			o.sensitive.add(name)
+		}
+	}
+}
+
+// sensitiveSet is a set of sensitive paths like "user.email".
+// Paths with the wildcard "*" like "filters.*.email" are kept apart so that most lookups are done by the map.
+type sensitiveSet struct {
+	names    map[string]bool
+	patterns []string
+}
+
+func (s *sensitiveSet) add(name string) {
+	if strings.Contains("."+name+".", ".*.") {
+		for _, p := range s.patterns {
+			if p == name {
+				return
+			}
+		}
+		s.patterns = append(s.patterns, name)
+		return
+	}
+	if s.names == nil {
+		s.names = make(map[string]bool)
+	}
+	s.names[name] = true
+}
+
+// clone returns a copy of s that can be extended without changing s. s may be nil.
+func (s *sensitiveSet) clone() *sensitiveSet {
+	c := &sensitiveSet{}
+	if s == nil {
+		return c
+	}
+	c.names = make(map[string]bool, len(s.names))
+	for name := range s.names {
+		c.names[name] = true
+	}
+	c.patterns = append(c.patterns, s.patterns...)
+	return c
+}
+
+// has returns true if the path like "user.email" or "ids.0" or its parent is sensitive.
+// The wildcard "*" matches any element like "filters.*.email" matches "filters.0.email". s may be nil.
+func (s *sensitiveSet) has(path string) bool {
+	if s == nil {
+		return false
+	}
+	if len(s.names) > 0 {
+		for p := path; ; {
+			if s.names[p] {
+				return true
+			}
+			i := strings.LastIndexByte(p, '.')
+			if i < 0 {
+				break
+			}
+			p = p[:i]
+		}
+	}
+	for _, pattern := range s.patterns {
+		if matchPath(pattern, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPath returns true if pattern matches path or its parent
+func matchPath(pattern, path string) bool {
+	for {
+		patternSeg, patternRest, patternMore := strings.Cut(pattern, ".")
+		seg, rest, more := strings.Cut(path, ".")
+		if patternSeg != "*" && patternSeg != seg {
+			return false
+		}
+		if !patternMore {
+			return true
+		}
+		if !more {
+			return false
+		}
+		pattern, path = patternRest, rest
+	}
+}
+
+// parseTag splits a struct tag like "email,sensitive" into the name and whether the sensitive option is set
+func parseTag(tag string) (string, bool) {
+	name, opts, _ := strings.Cut(tag, ",")
+	for _, opt := range strings.Split(opts, ",") {
+		if strings.TrimSpace(opt) == "sensitive" {
+			return name, true
+		}
+	}
+	return name, false
+}
+
+// sensitiveParams returns sensitive paths from the options and tags of the params.
+// Fields of struct elements of slices and maps are returned as wildcard paths like "filters.*.email",
+// and elements of interfaces like []any are resolved by their values like "filters.0.email".
+// It returns o.sensitive as it is if the params have no sensitive fields.
+func sensitiveParams(o *options, params interface{}) *sensitiveSet {
+	c := sensitiveCollector{base: o.sensitive}
+	rv, info := sensitiveValue(reflect.ValueOf(params))
+	if info != nil {
+		c.collect(rv, info, "", rv.Kind() == reflect.Struct, 0)
+	}
+	if c.result == nil {
+		return o.sensitive
+	}
+	return c.result
+}
+
+// sensitiveCollector collects sensitive paths of params. result is created when a path is found.
+type sensitiveCollector struct {
+	base   *sensitiveSet
+	result *sensitiveSet
+}
+
+func (c *sensitiveCollector) add(path string) {
+	if c.result == nil {
+		c.result = c.base.clone()
+	}
+	c.result.add(path)
+}
+
+// collect adds sensitive paths of v at prefix. info is the sensitiveInfo of the type of v.
+// Only parts of interface types are visited by the values, and the rest is taken from the cache of the types.
+// The flattened names are added if flatten is true in the same manner as encode.
+func (c *sensitiveCollector) collect(v reflect.Value, info *sensitiveInfo, prefix string, flatten bool, depth int) {
+	for _, p := range info.paths {
+		c.add(prefix + p)
+	}
+	if flatten {
+		for _, name := range info.flat {
+			c.add(name)
+		}
+	}
+	if !info.dynamic || depth >= maxExprDepth {
+		return
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		typ := v.Type()
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			if !field.IsExported() || !sensitiveTypeInfo(field.Type).dynamic {
+				continue
+			}
+			fv, fieldInfo := sensitiveValue(v.Field(i))
+			if fieldInfo == nil {
+				continue
+			}
+			name, _ := sensitiveTag(field.Tag, sensitiveTargetTags)
+			switch {
+			case name != "":
+				c.collect(fv, fieldInfo, prefix+name+".", flatten && field.Type.Kind() == reflect.Struct, depth+1)
+			case field.Type.Kind() == reflect.Struct:
+				c.collect(fv, fieldInfo, prefix, flatten, depth+1)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if ev, elemInfo := sensitiveValue(v.Index(i)); elemInfo != nil {
+				c.collect(ev, elemInfo, prefix+strconv.Itoa(i)+".", false, depth+1)
+			}
+		}
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return
+		}
+		iter := v.MapRange()
+		for iter.Next() {
+			if ev, elemInfo := sensitiveValue(iter.Value()); elemInfo != nil {
+				c.collect(ev, elemInfo, prefix+iter.Key().String()+".", false, depth+1)
+			}
+		}
+	}
+}
+
+// sensitiveValue dereferences pointers and interfaces of v and returns the sensitiveInfo of its type.
+// The info is nil if v has nothing sensitive.
+func sensitiveValue(v reflect.Value) (reflect.Value, *sensitiveInfo) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return v, nil
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return v, nil
+	}
+	info := sensitiveTypeInfo(v.Type())
+	if len(info.paths) == 0 && len(info.flat) == 0 && !info.dynamic {
+		return v, nil
+	}
+	return v, info
+}
+
+// sensitiveTargetTags are struct tags that have the sensitive option
+var sensitiveTargetTags = []string{"twowaysql", "db"}
+
+// sensitiveInfo is sensitive paths of a type relative to the value of the type
+type sensitiveInfo struct {
+	// paths are dotted paths like "address.city" and "filters.*.email"
+	paths []string
+	// flat are names of fields of nested structs flattened by encode
+	flat []string
+	// dynamic is true if the type has interfaces whose values must be visited
+	dynamic bool
+}
+
+// sensitiveTypes caches sensitiveInfo by reflect.Type not to walk the types on each evaluation
+var sensitiveTypes sync.Map
+
+func sensitiveTypeInfo(typ reflect.Type) *sensitiveInfo {
+	if info, ok := sensitiveTypes.Load(typ); ok {
+		return info.(*sensitiveInfo)
+	}
+	info, _ := sensitiveTypes.LoadOrStore(typ, newSensitiveInfo(typ, map[reflect.Type]bool{}))
+	return info.(*sensitiveInfo)
+}
+
+// newSensitiveInfo walks typ and collects tags with the sensitive option.
+// visited prevents recursion of self-referencing types.
+func newSensitiveInfo(typ reflect.Type, visited map[reflect.Type]bool) *sensitiveInfo {
+	info := &sensitiveInfo{}
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if visited[typ] {
+		return info
+	}
+	visited[typ] = true
+	defer delete(visited, typ)
+	switch typ.Kind() {
+	case reflect.Interface:
+		info.dynamic = true
+	case reflect.Map:
+		if typ.Key().Kind() != reflect.String {
+			break
+		}
+		fallthrough
+	case reflect.Slice, reflect.Array:
+		// all elements have the same fields
+		elem := newSensitiveInfo(typ.Elem(), visited)
+		info.dynamic = elem.dynamic
+		for _, p := range elem.paths {
+			info.paths = append(info.paths, "*."+p)
+		}
+	case reflect.Struct:
+		switch typ.PkgPath() {
+		case "database/sql", "time":
+			return info
+		}
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, sensitive := sensitiveTag(field.Tag, sensitiveTargetTags)
+			if sensitive && name != "" {
+				info.paths = append(info.paths, name)
+				info.flat = append(info.flat, name)
+			}
+			sub := newSensitiveInfo(field.Type, visited)
+			info.dynamic = info.dynamic || sub.dynamic
+			prefix := ""
+			if name != "" {
+				prefix = name + "."
+			} else if field.Type.Kind() != reflect.Struct {
+				continue
+			}
+			for _, p := range sub.paths {
+				info.paths = append(info.paths, prefix+p)
+			}
+			if field.Type.Kind() == reflect.Struct {
+				info.flat = append(info.flat, sub.flat...)
+			}
+		}
+	}
+	return info
+}
+
+// sensitiveTag returns the tag name of the field and whether it has the sensitive option
+func sensitiveTag(structTag reflect.StructTag, targetTags []string) (string, bool) {
+	for _, t := range targetTags {
+		if tag := structTag.Get(t); tag != "" {
+			return parseTag(tag)
+		}
+	}
+	return "", false
+}
+
+// redactArg returns Redacted keeping the name of named parameters
+func redactArg(arg interface{}) interface{} {
+	if named, ok := arg.(sql.NamedArg); ok {
+		return sql.Named(named.Name, Redacted)
+	}
+	return Redacted
+}
+
+// redactedError hides values of sensitive parameters in the message of the wrapped error
+type redactedError struct {
+	err error
+	msg string
+}
+
+func (e *redactedError) Error() string {
+	return e.msg
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// redactError replaces values of sensitive parameters in the message of err with Redacted.
+// Drivers may include bind values in errors like constraint violations. Only text values are replaced
+// not to break messages by short numbers. redacted is nil if no parameters are sensitive.
+func redactError(err error, args, redacted []interface{}) error {
+	if err == nil || redacted == nil {
+		return err
+	}
+	msg := err.Error()
+	replaced := msg
+	for i, arg := range args {
+		if !isRedacted(redacted[i]) {
+			continue
+		}
+		if named, ok := arg.(sql.NamedArg); ok {
+			arg = named.Value
+		}
+		var str string
+		switch v := arg.(type) {
+		case string:
+			str = v
+		case []byte:
+			str = string(v)
+		case Decimal:
+			str = string(v)
+		}
+		if str != "" {
+			replaced = strings.ReplaceAll(replaced, str, Redacted)
+		}
+	}
+	if replaced == msg {
+		return err
+	}
+	return &redactedError{err: err, msg: replaced}
+}
+
+func isRedacted(arg interface{}) bool {
+	if named, ok := arg.(sql.NamedArg); ok {
+		arg = named.Value
+	}
+	s, ok := arg.(string)
+	return ok && s == Redacted
+}
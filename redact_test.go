@@ -0,0 +1,238 @@
+package twowaysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/future-architect/go-twowaysql/private/testhelper"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"gotest.tools/v3/assert"
+)
+
+func TestTemplate_EvalRedacted(t *testing.T) {
+	type Address struct {
+		City string `twowaysql:"city,sensitive"`
+	}
+	type User struct {
+		Name     string   `twowaysql:"name"`
+		Email    string   `twowaysql:"email,sensitive"`
+		Phones   []string `twowaysql:"phones,sensitive"`
+		Password string   `db:"password, sensitive"`
+		Address  Address  `twowaysql:"address"`
+	}
+	type Row struct {
+		Name  string `twowaysql:"name"`
+		Email string `twowaysql:"email,sensitive"`
+	}
+	type Search struct {
+		Filters []Row          `twowaysql:"filters"`
+		Owners  map[string]Row `twowaysql:"owners"`
+	}
+
+	tests := []struct {
+		name         string
+		query        string
+		opts         []Option
+		params       any
+		wantQuery    string
+		wantArgs     []any
+		wantRedacted []any
+	}{
+		{
+			name:         "struct tag",
+			query:        `SELECT * FROM users WHERE name = /*name*/'bob' AND email = /*email*/'bob@example.com' AND password = /*password*/'x'`,
+			params:       &User{Name: "bob", Email: "bob@example.com", Password: "secret"},
+			wantQuery:    `SELECT * FROM users WHERE name = ?/*name*/ AND email = ?/*email*/ AND password = ?/*password*/`,
+			wantArgs:     []any{"bob", "bob@example.com", "secret"},
+			wantRedacted: []any{"bob", Redacted, Redacted},
+		},
+		{
+			name:         "slice and nested struct",
+			query:        `SELECT * FROM users WHERE phone IN /*phones*/('1') AND city = /*address.city*/'Tokyo' OR city = /*city*/'Tokyo'`,
+			params:       &User{Phones: []string{"090", "080"}, Address: Address{City: "Osaka"}},
+			wantQuery:    `SELECT * FROM users WHERE phone IN (?, ?)/*phones*/ AND city = ?/*address.city*/ OR city = ?/*city*/`,
+			wantArgs:     []any{"090", "080", "Osaka", "Osaka"},
+			wantRedacted: []any{Redacted, Redacted, Redacted, Redacted},
+		},
+		{
+			name:         "option",
+			query:        `SELECT * FROM users WHERE name = /*name*/'bob' AND email = /*email*/'bob@example.com'`,
+			opts:         []Option{WithSensitive("email"), WithPlaceholder(Named)},
+			params:       map[string]any{"name": "bob", "email": "bob@example.com"},
+			wantQuery:    `SELECT * FROM users WHERE name = :name/*name*/ AND email = :email/*email*/`,
+			wantArgs:     []any{sql.Named("name", "bob"), sql.Named("email", "bob@example.com")},
+			wantRedacted: []any{sql.Named("name", "bob"), sql.Named("email", Redacted)},
+		},
+		{
+			name:         "records",
+			query:        `INSERT INTO users (name, email) VALUES /*rows*/('bob', 'bob@example.com')`,
+			params:       map[string]any{"rows": []Row{{Name: "bob", Email: "bob@example.com"}, {Name: "tom", Email: "tom@example.com"}}},
+			wantQuery:    `INSERT INTO users (name, email) VALUES ((?, ?), (?, ?))/*rows*/`,
+			wantArgs:     []any{"bob", "bob@example.com", "tom", "tom@example.com"},
+			wantRedacted: []any{"bob", Redacted, "tom", Redacted},
+		},
+		{
+			name:         "FOR loop over slice of structs",
+			query:        `SELECT * FROM users WHERE /* FOR f IN filters */name = /*f.name*/'x' OR email = /*f.email*/'x'/* END */`,
+			params:       &Search{Filters: []Row{{Name: "bob", Email: "secret@a"}}},
+			wantQuery:    `SELECT * FROM users WHERE name = ?/*f.name*/ OR email = ?/*f.email*/`,
+			wantArgs:     []any{"bob", "secret@a"},
+			wantRedacted: []any{"bob", Redacted},
+		},
+		{
+			name:         "dotted path into slice and map",
+			query:        `SELECT * FROM users WHERE email = /*filters.0.email*/'x' OR email = /*owners.bob.email*/'x' OR name = /*owners.bob.name*/'x'`,
+			params:       map[string]any{"filters": []*Row{{Email: "secret@a"}}, "owners": map[string]Row{"bob": {Name: "bob", Email: "secret@b"}}},
+			wantQuery:    `SELECT * FROM users WHERE email = ?/*filters.0.email*/ OR email = ?/*owners.bob.email*/ OR name = ?/*owners.bob.name*/`,
+			wantArgs:     []any{"secret@a", "secret@b", "bob"},
+			wantRedacted: []any{Redacted, Redacted, "bob"},
+		},
+		{
+			name:         "FOR loop over interface slice",
+			query:        `SELECT * FROM users WHERE /* FOR f IN filters */email = /*f.email*/'x' OR /* END */1 = 0`,
+			params:       map[string]any{"filters": []any{Row{Name: "bob", Email: "secret@a"}, map[string]any{"email": "public@b"}}},
+			wantQuery:    `SELECT * FROM users WHERE email = ?/*f.email*/ OR email = ?/*f.email*/ OR 1 = 0`,
+			wantArgs:     []any{"secret@a", "public@b"},
+			wantRedacted: []any{Redacted, "public@b"},
+		},
+		{
+			name:         "wildcard option",
+			query:        `SELECT * FROM users WHERE /* FOR f IN filters */email = /*f.email*/'x'/* END */`,
+			opts:         []Option{WithSensitive("filters.*.email")},
+			params:       map[string]any{"filters": []map[string]any{{"email": "secret@a"}}},
+			wantQuery:    `SELECT * FROM users WHERE email = ?/*f.email*/`,
+			wantArgs:     []any{"secret@a"},
+			wantRedacted: []any{Redacted},
+		},
+		{
+			name:         "no sensitive parameters",
+			query:        `SELECT * FROM users WHERE name = /*name*/'bob'`,
+			params:       map[string]any{"name": "bob"},
+			wantQuery:    `SELECT * FROM users WHERE name = ?/*name*/`,
+			wantArgs:     []any{"bob"},
+			wantRedacted: []any{"bob"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := Compile(tt.query, tt.opts...)
+			assert.NilError(t, err)
+
+			query, args, err := tmpl.EvalContext(context.Background(), tt.params)
+			assert.NilError(t, err)
+			assert.Equal(t, tt.wantQuery, query)
+			assert.DeepEqual(t, tt.wantArgs, args, cmpopts.IgnoreUnexported(sql.NamedArg{}))
+
+			query, redacted, err := tmpl.EvalRedacted(context.Background(), tt.params)
+			assert.NilError(t, err)
+			assert.Equal(t, tt.wantQuery, query)
+			assert.DeepEqual(t, tt.wantRedacted, redacted, cmpopts.IgnoreUnexported(sql.NamedArg{}))
+		})
+	}
+}
+
+func TestSensitiveParams_Cache(t *testing.T) {
+	type Params struct {
+		Name    string `twowaysql:"name"`
+		Email   string `twowaysql:"email,sensitive"`
+		Filters []struct {
+			Email string `twowaysql:"email,sensitive"`
+		} `twowaysql:"filters"`
+	}
+	type Plain struct {
+		Name string `twowaysql:"name"`
+	}
+	o := newOptions([]Option{WithSensitive("password")})
+
+	got := sensitiveParams(o, &Params{})
+	assert.Assert(t, got.has("password"))
+	assert.Assert(t, got.has("email"))
+	assert.Assert(t, got.has("filters.3.email"))
+	assert.Assert(t, !got.has("name"))
+	assert.Assert(t, !o.sensitive.has("email"))
+
+	// types without sensitive fields reuse the options without walking the type again
+	plain := &Plain{}
+	allocs := testing.AllocsPerRun(100, func() {
+		if sensitiveParams(o, plain) != o.sensitive {
+			t.Fatal("options are not reused")
+		}
+	})
+	assert.Equal(t, float64(0), allocs)
+}
+
+func TestRedactError(t *testing.T) {
+	cause := errors.New(`duplicate key value: (email)=(bob@example.com), (name)=(bob)`)
+	err := redactError(cause, []any{"bob", "bob@example.com"}, []any{"bob", Redacted})
+	assert.Error(t, err, `duplicate key value: (email)=([REDACTED]), (name)=(bob)`)
+	assert.Assert(t, errors.Is(err, cause))
+
+	// errors without values are not wrapped
+	cause = errors.New("connection refused")
+	assert.Equal(t, cause, redactError(cause, []any{"bob@example.com"}, []any{Redacted}))
+	assert.Equal(t, cause, redactError(cause, []any{"bob"}, nil))
+}
+
+func TestRedact_Hooks(t *testing.T) {
+	type Person struct {
+		EmpNo     int    `twowaysql:"employee_no"`
+		FirstName string `twowaysql:"first_name,sensitive"`
+	}
+	var calls []string
+	hook := &recordingHook{name: "hook", calls: &calls}
+	tw := New(openSQLite(t), WithHooks(hook))
+	ctx := context.Background()
+
+	_, err := tw.Exec(ctx, `INSERT INTO persons (employee_no, first_name) VALUES (/*employee_no*/1, /*first_name*/'Jeff')`, &Person{EmpNo: 1, FirstName: "Jeff"})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []any{1, Redacted}, hook.events[0].Args)
+
+	_, err = tw.BulkExec(ctx, `INSERT INTO persons (employee_no, first_name) VALUES (/*employee_no*/1, /*first_name*/'Jeff')`, []Person{{EmpNo: 2, FirstName: "Sanjay"}, {EmpNo: 3, FirstName: "Ken"}})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []any{2, Redacted, 3, Redacted}, hook.events[1].Args)
+
+	var people []Person
+	err = tw.Select(ctx, &people, `SELECT * FROM persons WHERE first_name = /*first_name*/'Jeff' AND unknown = 1`, &Person{FirstName: "Jeff"})
+	assert.ErrorContains(t, err, "no such column: unknown")
+	assert.DeepEqual(t, []any{Redacted}, hook.events[2].Args)
+}
+
+func TestDocument_Sensitive(t *testing.T) {
+	doc, err := ParseMarkdownString(testhelper.TrimIndent(t, `
+	# Login
+
+	~~~sql
+	SELECT * FROM users WHERE name = /*name*/'bob' AND password = /*password*/'secret' AND pin = /*pin*/1234
+	~~~
+
+	## Parameters
+
+	| Name     | Type | Description                 |
+	|----------|------|-----------------------------|
+	| name     | text | user name                   |
+	| password | text | hashed password (sensitive) |
+	| pin      | int  | PIN code (Sensitive)        |
+	`))
+	assert.NilError(t, err)
+	assert.Assert(t, !doc.Params[0].Sensitive)
+	assert.Assert(t, doc.Params[1].Sensitive)
+	assert.Assert(t, doc.Params[2].Sensitive)
+
+	params := map[string]any{"name": "bob", "password": "secret", "pin": "1234"}
+	assert.DeepEqual(t, map[string]any{"name": "bob", "password": Redacted, "pin": Redacted}, doc.RedactParams(params))
+	assert.Equal(t, "secret", params["password"])
+
+	_, err = doc.ConvertParams(map[string]any{"pin": "12a4"})
+	assert.Error(t, err, fmt.Sprintf("parameter 'pin': %s is not a valid integer value", Redacted))
+
+	tmpl, err := Compile(doc.SQL, doc.Options()...)
+	assert.NilError(t, err)
+	converted, err := doc.ConvertParams(params)
+	assert.NilError(t, err)
+	_, args, err := tmpl.EvalRedacted(context.Background(), converted)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []any{"bob", Redacted, Redacted}, args)
+}
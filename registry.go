@@ -0,0 +1,329 @@
+package twowaysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Registry holds queries loaded from .sql and .sql.md files and runs them by name.
+// A query is looked up by the path in the file system or by the Title of the Markdown document.
+//
+//	//go:embed queries
+//	var queries embed.FS
+//
+//	registry, err := twowaysql.NewRegistry(db, queries)
+//	err = registry.Select(ctx, "queries/select_person.sql.md", &people, params)
+type Registry struct {
+	db    *sqlx.DB
+	fsys  fs.FS
+	opts  []Option
+	state atomic.Pointer[registryState]
+	// reload serializes Reload
+	reload sync.Mutex
+}
+
+// registryState is a snapshot of the queries that is swapped atomically by Reload
+type registryState struct {
+	// files holds queries by paths
+	files map[string]*registeredQuery
+	// queries holds queries by paths and titles
+	queries map[string]*registeredQuery
+	paths   []string
+	// failed holds stamps of files that can't be compiled not to report the same error again
+	failed map[string]fileStamp
+}
+
+// registeredQuery is a compiled query in Registry. doc is nil for .sql files.
+type registeredQuery struct {
+	doc   *Document
+	tmpl  *Template
+	stamp fileStamp
+}
+
+// fileStamp is used to detect changes of files by polling
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// ReloadResult is a result of Registry.Reload. Paths are sorted.
+type ReloadResult struct {
+	Added   []string
+	Updated []string
+	Removed []string
+	// Err has syntax errors of changed files joined by errors.Join.
+	// The last good versions of these files are kept.
+	Err error
+}
+
+// Changed returns true if any file is added, updated or removed, or an error occurs.
+func (r ReloadResult) Changed() bool {
+	return len(r.Added) > 0 || len(r.Updated) > 0 || len(r.Removed) > 0 || r.Err != nil
+}
+
+// NewRegistry loads and compiles all .sql and .sql.md files in fsys like embed.FS.
+// opts are used to compile all queries in addition to Document.Options of Markdown files.
+// It returns all syntax errors and duplicated titles joined by errors.Join.
+func NewRegistry(db *sqlx.DB, fsys fs.FS, opts ...Option) (*Registry, error) {
+	r := &Registry{
+		db:   db,
+		fsys: fsys,
+		opts: opts,
+	}
+	stamps, paths, err := r.scan()
+	if err != nil {
+		return nil, err
+	}
+	var errs []error
+	files := make(map[string]*registeredQuery, len(paths))
+	for _, path := range paths {
+		q, err := r.load(path, stamps[path])
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		files[path] = q
+	}
+	state, titleErrs := newRegistryState(files, nil)
+	if errs = append(errs, titleErrs...); len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	r.state.Store(state)
+	return r, nil
+}
+
+// scan returns stamps and sorted paths of .sql and .sql.md files
+func (r *Registry) scan() (map[string]fileStamp, []string, error) {
+	stamps := make(map[string]fileStamp)
+	var paths []string
+	err := fs.WalkDir(r.fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !(strings.HasSuffix(path, ".sql") || strings.HasSuffix(path, ".sql.md")) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		stamps[path] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Strings(paths)
+	return stamps, paths, nil
+}
+
+// newRegistryState makes a state that looks up files by paths and titles.
+// A title used by multiple files is an error and refers to the first file.
+func newRegistryState(files map[string]*registeredQuery, failed map[string]fileStamp) (*registryState, []error) {
+	state := &registryState{
+		files:   files,
+		queries: make(map[string]*registeredQuery, len(files)*2),
+		failed:  failed,
+	}
+	for path := range files {
+		state.paths = append(state.paths, path)
+	}
+	sort.Strings(state.paths)
+	var errs []error
+	titles := make(map[string]string)
+	for _, path := range state.paths {
+		q := files[path]
+		state.queries[path] = q
+		if q.doc == nil || q.doc.Title == "" {
+			continue
+		}
+		if prev, ok := titles[q.doc.Title]; ok {
+			errs = append(errs, fmt.Errorf("%s: title '%s' is already used by %s", path, q.doc.Title, prev))
+			continue
+		}
+		titles[q.doc.Title] = path
+	}
+	for title, path := range titles {
+		if _, ok := state.queries[title]; !ok {
+			state.queries[title] = files[path]
+		}
+	}
+	return state, errs
+}
+
+func (r *Registry) load(path string, stamp fileStamp) (*registeredQuery, error) {
+	src, err := fs.ReadFile(r.fsys, path)
+	if err != nil {
+		return nil, err
+	}
+	q := &registeredQuery{stamp: stamp}
+	opts := append([]Option(nil), r.opts...)
+	if strings.HasSuffix(path, ".md") {
+		q.doc, err = ParseMarkdownString(string(src))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		opts = append(opts, q.doc.Options()...)
+		q.tmpl, err = Compile(q.doc.SQL, append(opts, WithSource(path, q.doc.SQLLine))...)
+	} else {
+		q.tmpl, err = Compile(string(src), append(opts, WithSource(path, 1))...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// Reload re-parses added and changed files and swaps the queries atomically.
+// If a changed file can't be compiled, the last good version is kept and the error is reported in ReloadResult.Err.
+// Files are compared by the modification time and the size.
+func (r *Registry) Reload() ReloadResult {
+	r.reload.Lock()
+	defer r.reload.Unlock()
+
+	var result ReloadResult
+	prev := r.state.Load()
+	stamps, paths, err := r.scan()
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	var errs []error
+	files := make(map[string]*registeredQuery, len(paths))
+	failed := make(map[string]fileStamp)
+	for _, path := range paths {
+		stamp := stamps[path]
+		old, exists := prev.files[path]
+		if exists && old.stamp == stamp {
+			files[path] = old
+			continue
+		}
+		if s, ok := prev.failed[path]; ok && s == stamp {
+			// the same broken version
+			failed[path] = stamp
+			if exists {
+				files[path] = old
+			}
+			continue
+		}
+		q, err := r.load(path, stamp)
+		if err != nil {
+			errs = append(errs, err)
+			failed[path] = stamp
+			if exists {
+				files[path] = old
+			}
+			continue
+		}
+		files[path] = q
+		if exists {
+			result.Updated = append(result.Updated, path)
+		} else {
+			result.Added = append(result.Added, path)
+		}
+	}
+	for _, path := range prev.paths {
+		if _, ok := stamps[path]; !ok {
+			result.Removed = append(result.Removed, path)
+		}
+	}
+	state, titleErrs := newRegistryState(files, failed)
+	// duplicated titles are reported only when files are changed not to report them every time
+	if result.Changed() || len(errs) > 0 {
+		errs = append(errs, titleErrs...)
+	}
+	result.Err = errors.Join(errs...)
+	r.state.Store(state)
+	return result
+}
+
+// Watch polls the file system every interval and reloads changed files until ctx is done.
+// It is for development with os.DirFS. callback is called with the result when something is changed.
+//
+//	registry, err := twowaysql.NewRegistry(db, os.DirFS("queries"))
+//	go registry.Watch(ctx, time.Second, func(result twowaysql.ReloadResult) {
+//		log.Println(result.Updated, result.Err)
+//	})
+func (r *Registry) Watch(ctx context.Context, interval time.Duration, callback func(ReloadResult)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if result := r.Reload(); result.Changed() && callback != nil {
+				callback(result)
+			}
+		}
+	}
+}
+
+func (r *Registry) lookup(name string) (*registeredQuery, error) {
+	q, ok := r.state.Load().queries[name]
+	if !ok {
+		return nil, fmt.Errorf("query '%s' is not found in the registry", name)
+	}
+	return q, nil
+}
+
+// Names returns paths of the registered queries in sorted order.
+func (r *Registry) Names() []string {
+	return append([]string(nil), r.state.Load().paths...)
+}
+
+// Template returns the compiled query of name. It can be used with TwowaysqlTx.SelectTemplate in transactions.
+func (r *Registry) Template(name string) (*Template, error) {
+	q, err := r.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	return q.tmpl, nil
+}
+
+// Document returns the Markdown document of name. It returns nil for .sql files.
+func (r *Registry) Document(name string) (*Document, error) {
+	q, err := r.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	return q.doc, nil
+}
+
+// Select runs the query of name like Twowaysql.Select.
+func (r *Registry) Select(ctx context.Context, name string, dest interface{}, params interface{}) error {
+	q, err := r.lookup(name)
+	if err != nil {
+		return err
+	}
+	return New(r.db, r.opts...).SelectTemplate(ctx, dest, q.tmpl, params)
+}
+
+// Get runs the query of name like Twowaysql.Get.
+func (r *Registry) Get(ctx context.Context, name string, dest interface{}, params interface{}) error {
+	q, err := r.lookup(name)
+	if err != nil {
+		return err
+	}
+	return New(r.db, r.opts...).GetTemplate(ctx, dest, q.tmpl, params)
+}
+
+// Exec runs the query of name like Twowaysql.Exec.
+func (r *Registry) Exec(ctx context.Context, name string, params interface{}) (sql.Result, error) {
+	q, err := r.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	return New(r.db, r.opts...).ExecTemplate(ctx, q.tmpl, params)
+}
@@ -0,0 +1,176 @@
+package twowaysql
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+var registryFS = fstest.MapFS{
+	"queries/insert_person.sql": {Data: []byte(`INSERT INTO persons (employee_no, first_name) VALUES (/*employee_no*/1, /*first_name*/'Jeff')`)},
+	"queries/select_person.sql.md": {Data: []byte("# Select Person\n\n" +
+		"```sql\nSELECT first_name FROM persons WHERE employee_no = /*employee_no*/1 ORDER BY /*$sort*/first_name\n```\n\n" +
+		"## Parameters\n\n" +
+		"| Name        | Type | AllowedValues          | Description |\n" +
+		"|-------------|------|------------------------|-------------|\n" +
+		"| employee_no | int  |                        |             |\n" +
+		"| sort        | text | first_name, last_name  |             |\n")},
+	"queries/readme.md": {Data: []byte("# not a query")},
+}
+
+func TestRegistry(t *testing.T) {
+	db := openSQLite(t)
+	ctx := context.Background()
+
+	registry, err := NewRegistry(db, registryFS)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{"queries/insert_person.sql", "queries/select_person.sql.md"}, registry.Names())
+
+	_, err = registry.Exec(ctx, "queries/insert_person.sql", map[string]any{"employee_no": 1, "first_name": "Dan"})
+	assert.NilError(t, err)
+
+	var people []struct {
+		FirstName string `db:"first_name"`
+	}
+	// by path
+	err = registry.Select(ctx, "queries/select_person.sql.md", &people, map[string]any{"employee_no": 1, "sort": "first_name"})
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(people))
+	assert.Equal(t, "Dan", people[0].FirstName)
+
+	// by title
+	people = nil
+	err = registry.Select(ctx, "Select Person", &people, map[string]any{"employee_no": 1, "sort": "last_name"})
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(people))
+
+	var name string
+	err = registry.Get(ctx, "Select Person", &name, map[string]any{"employee_no": 1, "sort": "first_name"})
+	assert.NilError(t, err)
+	assert.Equal(t, "Dan", name)
+
+	// allowed values of the document are used
+	err = registry.Select(ctx, "Select Person", &people, map[string]any{"employee_no": 1, "sort": "email; DROP TABLE persons"})
+	assert.ErrorContains(t, err, "not allowed")
+
+	doc, err := registry.Document("Select Person")
+	assert.NilError(t, err)
+	assert.Equal(t, "Select Person", doc.Title)
+
+	_, err = registry.Template("unknown")
+	assert.Error(t, err, "query 'unknown' is not found in the registry")
+}
+
+func TestRegistry_Hooks(t *testing.T) {
+	recorder := &spanRecorder{}
+	registry, err := NewRegistry(openSQLite(t), registryFS, WithHooks(NewTracingHook(recorder)))
+	assert.NilError(t, err)
+
+	_, err = registry.Exec(context.Background(), "queries/insert_person.sql", map[string]any{"employee_no": 1, "first_name": "Dan"})
+	assert.NilError(t, err)
+	spans := recorder.Spans()
+	assert.Equal(t, 1, len(spans))
+	assert.Equal(t, "queries/insert_person.sql", spans[0].Attributes["code.filepath"])
+	assert.Equal(t, 1, spans[0].Attributes["code.lineno"])
+}
+
+func TestRegistry_Errors(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.sql":    {Data: []byte("SELECT * FROM persons\nWHERE /* IF true */employee_no = 1")},
+		"b.sql.md": {Data: []byte("# Person\n\n```sql\nSELECT * FROM persons /* END */\n```\n")},
+		"c.sql.md": {Data: []byte("# Person\n\n```sql\nSELECT * FROM persons\n```\n")},
+		"d.sql.md": {Data: []byte("# Person\n\n```sql\nSELECT * FROM persons\n```\n")},
+	}
+	_, err := NewRegistry(nil, fsys)
+	assert.Error(t, err, "a.sql:2:7: can not parse: not found /* END */\n"+
+		"WHERE /* IF true */employee_no = 1\n"+
+		"      ^\n"+
+		"b.sql.md:4:23: can not parse: unexpected /* END */\n"+
+		"SELECT * FROM persons /* END */\n"+
+		"                      ^\n"+
+		"d.sql.md: title 'Person' is already used by c.sql.md")
+}
+
+func TestRegistry_Reload(t *testing.T) {
+	modTime := time.Date(2022, 9, 13, 10, 30, 0, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"a.sql": {Data: []byte("SELECT 1"), ModTime: modTime},
+		"b.sql": {Data: []byte("SELECT 2"), ModTime: modTime},
+	}
+	registry, err := NewRegistry(nil, fsys)
+	assert.NilError(t, err)
+	query := func(name string) string {
+		tmpl, err := registry.Template(name)
+		assert.NilError(t, err)
+		return tmpl.String()
+	}
+
+	// nothing changed
+	result := registry.Reload()
+	assert.Assert(t, !result.Changed())
+
+	// update, add and remove
+	fsys["a.sql"] = &fstest.MapFile{Data: []byte("SELECT 10"), ModTime: modTime.Add(time.Second)}
+	fsys["c.sql"] = &fstest.MapFile{Data: []byte("SELECT 3"), ModTime: modTime}
+	delete(fsys, "b.sql")
+	result = registry.Reload()
+	assert.NilError(t, result.Err)
+	assert.DeepEqual(t, []string{"c.sql"}, result.Added)
+	assert.DeepEqual(t, []string{"a.sql"}, result.Updated)
+	assert.DeepEqual(t, []string{"b.sql"}, result.Removed)
+	assert.Equal(t, "SELECT 10", query("a.sql"))
+	assert.DeepEqual(t, []string{"a.sql", "c.sql"}, registry.Names())
+
+	// syntax error keeps the last good version
+	fsys["a.sql"] = &fstest.MapFile{Data: []byte("SELECT /* IF true */ 11"), ModTime: modTime.Add(2 * time.Second)}
+	result = registry.Reload()
+	assert.Error(t, result.Err, "a.sql:1:8: can not parse: not found /* END */\n"+
+		"SELECT /* IF true */ 11\n"+
+		"       ^")
+	assert.Assert(t, len(result.Updated) == 0)
+	assert.Equal(t, "SELECT 10", query("a.sql"))
+
+	// the same error is not reported again
+	result = registry.Reload()
+	assert.Assert(t, !result.Changed())
+
+	// fixed
+	fsys["a.sql"] = &fstest.MapFile{Data: []byte("SELECT /* IF true */ 11 /* END */"), ModTime: modTime.Add(3 * time.Second)}
+	result = registry.Reload()
+	assert.NilError(t, result.Err)
+	assert.DeepEqual(t, []string{"a.sql"}, result.Updated)
+	assert.Equal(t, "SELECT /* IF true */ 11 /* END */", query("a.sql"))
+}
+
+func TestRegistry_Watch(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.sql")
+	assert.NilError(t, os.WriteFile(path, []byte("SELECT 1"), 0o644))
+	registry, err := NewRegistry(nil, os.DirFS(dir))
+	assert.NilError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	results := make(chan ReloadResult, 1)
+	go registry.Watch(ctx, 10*time.Millisecond, func(result ReloadResult) {
+		results <- result
+	})
+
+	assert.NilError(t, os.WriteFile(path, []byte("SELECT 2"), 0o644))
+	assert.NilError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Minute)))
+	select {
+	case result := <-results:
+		assert.NilError(t, result.Err)
+		assert.DeepEqual(t, []string{"a.sql"}, result.Updated)
+	case <-time.After(5 * time.Second):
+		t.Fatal("not reloaded")
+	}
+	tmpl, err := registry.Template("a.sql")
+	assert.NilError(t, err)
+	assert.Equal(t, "SELECT 2", tmpl.String())
+}
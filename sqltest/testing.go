@@ -29,7 +29,11 @@ func (c callbackForTest) InsertFixtureTable(doc *twowaysql.Document, tc twowaysq
 }
 
 func (c callbackForTest) Exec(doc *twowaysql.Document, tc twowaysql.TestCase) {
-	c.t.Logf("Exec Query with: %v", tc.Params)
+	params := make(map[string]any, len(tc.Params))
+	for k, v := range tc.Params {
+		params[k] = v
+	}
+	c.t.Logf("Exec Query with: %v", doc.RedactParams(params))
 }
 
 func (c callbackForTest) ExecTestQuery(doc *twowaysql.Document, tc twowaysql.TestCase) {
@@ -74,7 +78,7 @@ type Callback interface {
 
 func Run(ctx context.Context, db *sqlx.DB, doc *twowaysql.Document, cb Callback) (failureCount, errCount int, err error) {
 	err = func() error {
-		tws := twowaysql.New(db)
+		tws := twowaysql.New(db, doc.Options()...)
 		tx, err := tws.Begin(ctx)
 		if err != nil {
 			return fmt.Errorf("database connection test error: %w", err)
@@ -89,7 +93,7 @@ func Run(ctx context.Context, db *sqlx.DB, doc *twowaysql.Document, cb Callback)
 		func() {
 			cb.StartTest(doc, tc)
 
-			tws := twowaysql.New(db)
+			tws := twowaysql.New(db, doc.Options()...)
 			tx, err := tws.Begin(ctx)
 			if err != nil {
 				errCount++
@@ -134,10 +138,20 @@ func Run(ctx context.Context, db *sqlx.DB, doc *twowaysql.Document, cb Callback)
 					return
 				}
 			}
+			params := make(map[string]any, len(tc.Params))
+			for k, v := range tc.Params {
+				params[k] = v
+			}
+			params, err = doc.ConvertParams(params)
+			if err != nil {
+				errCount++
+				cb.EndTest(doc, tc, nil, fmt.Errorf("params error in %s: %w", tc.Name, err))
+				return
+			}
 			var result []map[string]any
 			if tc.TestQuery == "" {
 				cb.Exec(doc, tc)
-				err := tx.Select(ctx, &result, doc.SQL, tc.Params)
+				err := tx.Select(ctx, &result, doc.SQL, params)
 				if err != nil {
 					errCount++
 					cb.EndTest(doc, tc, nil, fmt.Errorf("exec SQL error in %s: %w", tc.Name, err))
@@ -145,7 +159,7 @@ func Run(ctx context.Context, db *sqlx.DB, doc *twowaysql.Document, cb Callback)
 				}
 			} else {
 				cb.Exec(doc, tc)
-				_, err := tx.Exec(ctx, doc.SQL, tc.Params)
+				_, err := tx.Exec(ctx, doc.SQL, params)
 				if err != nil {
 					errCount++
 					cb.EndTest(doc, tc, nil, fmt.Errorf("exec SQL error in %s: %w", tc.Name, err))
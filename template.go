@@ -0,0 +1,211 @@
+package twowaysql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Option configures how a 2WaySQL query is compiled and evaluated.
+type Option func(*options)
+
+type options struct {
+	engine      ConditionEngine
+	allowed     map[string][]string
+	placeholder Placeholder
+	emptySlice  EmptySliceStrategy
+	converters  converters
+	format      Format
+	cleanup     bool
+	// for SyntaxError
+	file string
+	line int
+	// for BulkExec
+	maxPlaceholders int
+	// for Twowaysql
+	hooks []Hook
+	// for redaction
+	sensitive *sensitiveSet
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		engine: ExprEngine{},
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithConditionEngine sets the engine to compile /* IF ... */ and /* ELIF ... */ conditions.
+// Default engine is ExprEngine.
+func WithConditionEngine(engine ConditionEngine) Option {
+	return func(o *options) {
+		o.engine = engine
+	}
+}
+
+// WithAllowedValues registers values that can be embedded by the raw substitution /*$name*/.
+// Raw substitution writes the value into the query directly instead of a placeholder,
+// so it is only for identifiers like ORDER BY columns. Values not in the list are rejected.
+// It can be called multiple times for the same name to add values.
+func WithAllowedValues(name string, values ...string) Option {
+	return func(o *options) {
+		if o.allowed == nil {
+			o.allowed = make(map[string][]string)
+		}
+		o.allowed[name] = append(o.allowed[name], values...)
+	}
+}
+
+// EmptySliceStrategy decides how an empty slice bound to /*value*/(...) is evaluated.
+type EmptySliceStrategy int
+
+const (
+	// EmptySliceError returns an error. Default.
+	EmptySliceError EmptySliceStrategy = iota
+	// EmptySliceNull emits (NULL). "x IN (NULL)" matches no rows.
+	EmptySliceNull
+)
+
+// WithEmptySlice sets how an empty slice bind value is evaluated.
+// "IN ()" is a syntax error on most databases, so it is an error by default.
+func WithEmptySlice(s EmptySliceStrategy) Option {
+	return func(o *options) {
+		o.emptySlice = s
+	}
+}
+
+// locate sets the position to SyntaxError
+func (o *options) locate(query string, err error) error {
+	var syntaxErr *SyntaxError
+	if errors.As(err, &syntaxErr) {
+		syntaxErr.locate(query, o.file, o.line)
+	}
+	return err
+}
+
+// Template is a parsed 2WaySQL query.
+// It is immutable and safe for concurrent use by multiple goroutines.
+type Template struct {
+	query string
+	tree  *tree
+	opts  *options
+}
+
+// Compile parses a 2WaySQL query and returns a Template that can be evaluated many times.
+// Conditions of IF/ELIF are also compiled here, and raw substitutions are checked to have allowed values.
+// Errors in the query are returned as *SyntaxError that has the position.
+func Compile(query string, opts ...Option) (*Template, error) {
+	o := newOptions(opts)
+
+	tokens, err := tokenize(query)
+	if err != nil {
+		return nil, o.locate(query, err)
+	}
+
+	tree, err := ast(tokens)
+	if err != nil {
+		return nil, o.locate(query, err)
+	}
+
+	if err := tree.compileConditions(o.engine); err != nil {
+		return nil, o.locate(query, err)
+	}
+
+	if err := tree.compileRaws(o.allowed); err != nil {
+		return nil, err
+	}
+
+	return &Template{
+		query: query,
+		tree:  tree,
+		opts:  o,
+	}, nil
+}
+
+// MustCompile is like Compile but panics if the query cannot be parsed.
+// It simplifies safe initialization of global variables holding templates.
+func MustCompile(query string, opts ...Option) *Template {
+	t, err := Compile(query, opts...)
+	if err != nil {
+		panic(fmt.Sprintf("twowaysql: Compile(%q): %v", query, err))
+	}
+	return t
+}
+
+// Eval returns converted query and bind value.
+// inputParams takes a tagged struct. Tags must be in the form `twowaysql:"tag_name"`.
+// The return value is expected to be used to issue queries to the database
+func (t *Template) Eval(inputParams interface{}) (string, []interface{}, error) {
+	return t.EvalContext(context.Background(), inputParams)
+}
+
+// EvalContext is like Eval but stops evaluation of conditions when ctx is done.
+// In that case, it returns *EvalCanceledError.
+func (t *Template) EvalContext(ctx context.Context, inputParams interface{}) (string, []interface{}, error) {
+	query, params, _, err := t.eval(ctx, inputParams)
+	return query, params, err
+}
+
+// EvalRedacted is like EvalContext but returns Redacted instead of bind values of sensitive parameters.
+// It is for logs and outputs, not for queries. Sensitive parameters are marked by WithSensitive or
+// the tag option like `twowaysql:"email,sensitive"`.
+func (t *Template) EvalRedacted(ctx context.Context, inputParams interface{}) (string, []interface{}, error) {
+	query, params, redacted, err := t.eval(ctx, inputParams)
+	if redacted == nil {
+		return query, params, err
+	}
+	return query, redacted, err
+}
+
+// eval returns the query, bind values and redacted bind values. redacted is nil if no sensitive values are bound.
+func (t *Template) eval(ctx context.Context, inputParams interface{}) (string, []interface{}, []interface{}, error) {
+	mapParams := map[string]interface{}{}
+
+	if inputParams != nil {
+		if err := encode(mapParams, inputParams); err != nil {
+			return "", nil, nil, err
+		}
+	} else {
+		mapParams = nil
+	}
+
+	sensitive := sensitiveParams(t.opts, inputParams)
+	generatedTokens, err := t.tree.parseContext(ctx, mapParams, t.opts, sensitive)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	if t.opts.cleanup {
+		mark := ""
+		if t.opts.format == FormatLines {
+			mark = dropMark
+		}
+		generatedTokens, err = cleanup(generatedTokens, mark)
+		if err != nil {
+			return "", nil, nil, err
+		}
+	}
+
+	convertedQuery, params, redacted, err := build(generatedTokens, mapParams, t.opts, sensitive)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	return format(convertedQuery, t.opts.format), params, redacted, nil
+}
+
+// rebind converts placeholders of the evaluated query by rebind if the template emits ?.
+func (t *Template) rebind(query string, rebind func(string) string) string {
+	if t.opts.placeholder != Question {
+		return query
+	}
+	return rebind(query)
+}
+
+// String returns the source query of the template.
+func (t *Template) String() string {
+	return t.query
+}
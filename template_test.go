@@ -0,0 +1,133 @@
+package twowaysql
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		inputParams Info
+		wantQuery   string
+		wantParams  []interface{}
+	}{
+		{
+			name:        "no comment",
+			input:       `SELECT * FROM person WHERE employee_no < 1000`,
+			inputParams: Info{},
+			wantQuery:   `SELECT * FROM person WHERE employee_no < 1000`,
+			wantParams:  []interface{}{},
+		},
+		{
+			name:  "if and bind",
+			input: `SELECT * FROM person WHERE employee_no < /*maxEmpNo*/1000 /* IF deptNo */ AND dept_no < /*deptNo*/1 /* END */`,
+			inputParams: Info{
+				MaxEmpNo: 3,
+				DeptNo:   12,
+			},
+			wantQuery:  `SELECT * FROM person WHERE employee_no < ?/*maxEmpNo*/ AND dept_no < ?/*deptNo*/`,
+			wantParams: []interface{}{3, 12},
+		},
+		{
+			name:  "statement after nested if",
+			input: `SELECT * FROM person WHERE /* IF true */ /* IF false */ dept_no = 1 /* ELSE */ id = 3 /* END */ AND boss_id = 4 /* END */ ORDER BY id`,
+			inputParams: Info{
+				MaxEmpNo: 3,
+			},
+			wantQuery:  `SELECT * FROM person WHERE id = 3 AND boss_id = 4 ORDER BY id`,
+			wantParams: []interface{}{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := Compile(tt.input)
+			assert.NilError(t, err)
+			assert.Equal(t, tt.input, tmpl.String())
+			// evaluate twice to make sure that the template is not modified
+			for i := 0; i < 2; i++ {
+				query, params, err := tmpl.Eval(&tt.inputParams)
+				assert.NilError(t, err)
+				assert.Check(t, cmp.DeepEqual(tt.wantParams, params))
+				assert.Check(t, cmp.DeepEqual(tt.wantQuery, query))
+			}
+		})
+	}
+}
+
+func TestCompileShouldReturnError(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		wantError string
+	}{
+		{
+			name:      "bad comment format",
+			input:     "SELECT * FROM person WHERE employee_no < 1000 /* IF true / AND dept_no = 1",
+			wantError: "Comment enclosing characters do not match",
+		},
+		{
+			name:      "no END",
+			input:     `SELECT * FROM person WHERE employee_no < 1000 /* IF true */ AND dept_no = 1`,
+			wantError: "can not parse: not found /* END */",
+		},
+		{
+			name:      "extra END",
+			input:     "SELECT * FROM person WHERE employee_no < 1000  AND dept_no = 1 /* END */",
+			wantError: "can not parse: unexpected /* END */",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile(tt.input)
+			assert.Equal(t, tt.wantError, syntaxErrorMsg(err))
+		})
+	}
+}
+
+func TestMustCompile(t *testing.T) {
+	defer func() {
+		r := recover()
+		assert.Check(t, r != nil)
+	}()
+	MustCompile(`SELECT * FROM person /* IF true */`)
+}
+
+func TestTemplate_EvalParallel(t *testing.T) {
+	tmpl := MustCompile(`SELECT * FROM person WHERE employee_no = /*EmpNo*/1 /* IF deptNo > 10 */ AND dept_no = /*deptNo*/1 /* END */`)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 100)
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			query, params, err := tmpl.Eval(&Info{EmpNo: i, DeptNo: i})
+			if err != nil {
+				errs <- err
+				return
+			}
+			wantQuery := `SELECT * FROM person WHERE employee_no = ?/*EmpNo*/`
+			wantParams := []interface{}{i}
+			if i > 10 {
+				wantQuery += ` AND dept_no = ?/*deptNo*/`
+				wantParams = append(wantParams, i)
+			}
+			if query != wantQuery || !interfaceSliceEqual(params, wantParams) {
+				errs <- fmt.Errorf("unexpected result for %d: %s %v", i, query, params)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
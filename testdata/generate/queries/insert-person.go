@@ -0,0 +1,31 @@
+// Code generated by twowaysql generate go. DO NOT EDIT.
+
+package queries
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+
+	"github.com/future-architect/go-twowaysql"
+)
+
+//go:embed insert-person.sql
+var insertPersonSQL string
+
+var insertPersonTemplate = twowaysql.MustCompile(insertPersonSQL)
+
+// InsertPersonParams is parameters of Insert Person.
+type InsertPersonParams struct {
+	EmployeeNo int64  `twowaysql:"employee_no"`
+	DeptNo     int64  `twowaysql:"dept_no"`
+	FirstName  string `twowaysql:"first_name"`
+	// mail (sensitive)
+	Email   string         `twowaysql:"email,sensitive"`
+	Profile twowaysql.JSON `twowaysql:"profile"`
+}
+
+// InsertPerson runs Insert Person.
+func InsertPerson(ctx context.Context, db twowaysql.Querier, params InsertPersonParams) (sql.Result, error) {
+	return db.ExecTemplate(ctx, insertPersonTemplate, &params)
+}
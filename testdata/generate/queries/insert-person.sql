@@ -0,0 +1,2 @@
+INSERT INTO persons (employee_no, dept_no, first_name, email, profile)
+VALUES (/*employee_no*/1, /*dept_no*/10, /*first_name*/'Dan', /*email*/'dan@example.com', /*profile*/'{}');
@@ -0,0 +1,31 @@
+// Code generated by twowaysql generate go. DO NOT EDIT.
+
+package queries
+
+import (
+	"context"
+	_ "embed"
+	"time"
+
+	"github.com/future-architect/go-twowaysql"
+)
+
+//go:embed search_persons.sql
+var searchPersonsSQL string
+
+var searchPersonsTemplate = twowaysql.MustCompile(searchPersonsSQL, twowaysql.WithAllowedValues("sort", "email", "first_name"))
+
+// SearchPersonsParams is parameters of Search Persons.
+type SearchPersonsParams struct {
+	// department numbers
+	DeptNos []int64    `twowaysql:"dept_nos"`
+	Since   *time.Time `twowaysql:"since"`
+	// sort key
+	Sort string `twowaysql:"sort"`
+}
+
+// SearchPersons runs Search Persons.
+// dest takes a pointer to a slice of a struct. The struct tag format must be `db:"tag_name"`.
+func SearchPersons(ctx context.Context, db twowaysql.Querier, dest any, params SearchPersonsParams) error {
+	return db.SelectTemplate(ctx, dest, searchPersonsTemplate, &params)
+}
@@ -0,0 +1,5 @@
+SELECT email, first_name, last_name FROM persons
+WHERE 1=1
+  /* IF dept_nos */AND dept_no IN /*dept_nos*/(1)/* END */
+  /* IF since */AND created_at >= /*since*/'2022-01-01'/* END */
+ORDER BY /*$sort*/email
@@ -0,0 +1,40 @@
+// Code generated by twowaysql generate go. DO NOT EDIT.
+
+package queries
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"time"
+
+	"github.com/future-architect/go-twowaysql"
+)
+
+//go:embed search_persons.sql
+var searchPersonsSQL string
+
+var searchPersonsTemplate = twowaysql.MustCompile(searchPersonsSQL, twowaysql.WithAllowedValues("sort", "email", "first_name"))
+
+// SearchPersonsParams is parameters of Search Persons.
+type SearchPersonsParams struct {
+	// department numbers
+	DeptNos []int64    `twowaysql:"dept_nos"`
+	Since   *time.Time `twowaysql:"since"`
+	// sort key
+	Sort string `twowaysql:"sort"`
+}
+
+// SearchPersonsResult is a row of Search Persons.
+type SearchPersonsResult struct {
+	Email     string           `db:"email"`
+	FirstName string           `db:"first_name"`
+	LastName  sql.Null[string] `db:"last_name"`
+}
+
+// SearchPersons runs Search Persons.
+func SearchPersons(ctx context.Context, db twowaysql.Querier, params SearchPersonsParams) ([]SearchPersonsResult, error) {
+	var result []SearchPersonsResult
+	err := db.SelectTemplate(ctx, &result, searchPersonsTemplate, &params)
+	return result, err
+}
@@ -1,7 +1,7 @@
 package twowaysql
 
 import (
-	"errors"
+	"fmt"
 	"strings"
 	"unicode"
 )
@@ -16,140 +16,329 @@ const (
 	tkEnd
 	tkBind
 	tkEndOfProgram
+	tkFor
+	tkSeparator
+	tkRaw
 )
 
 type token struct {
 	kind      tokenKind
 	str       string
-	value     string /* for Bind */
-	condition string /* for IF/ELIF */
+	value     string /* for Bind, Raw, loop variable of FOR and separator of SEPARATOR */
+	condition string /* for IF/ELIF and collection of FOR */
+	columns   string /* column list of Bind like people(first_name,last_name) */
+	pos       int    /* byte offset in the query */
+}
+
+func (k tokenKind) String() string {
+	switch k {
+	case tkSQLStmt:
+		return "SQL"
+	case tkIf:
+		return "IF"
+	case tkElif:
+		return "ELIF"
+	case tkElse:
+		return "ELSE"
+	case tkEnd:
+		return "END"
+	case tkBind:
+		return "bind"
+	case tkEndOfProgram:
+		return "end of query"
+	case tkFor:
+		return "FOR"
+	case tkSeparator:
+		return "SEPARATOR"
+	case tkRaw:
+		return "raw"
+	default:
+		return fmt.Sprintf("tokenKind(%d)", int(k))
+	}
+}
+
+// describe returns the token for error messages like /* END */
+func (t *token) describe() string {
+	switch t.kind {
+	case tkIf, tkElif, tkElse, tkEnd, tkFor, tkSeparator:
+		return "/* " + t.kind.String() + " */"
+	case tkEndOfProgram:
+		return "end of query"
+	default:
+		return fmt.Sprintf("%q", t.str)
+	}
+}
+
+// directives are keywords recognized at the beginning of comments
+var directives = map[string]tokenKind{
+	"IF":        tkIf,
+	"ELIF":      tkElif,
+	"ELSE":      tkElse,
+	"END":       tkEnd,
+	"FOR":       tkFor,
+	"SEPARATOR": tkSeparator,
+}
+
+// lexer splits a query into tokens.
+// It skips string literals ('...'), quoted identifiers ("..." and `...`),
+// dollar quoted strings ($$...$$ and $tag$...$tag$), line comments (-- ...) and hints (/*+ ... */),
+// so that /* in them is not taken as a 2WaySQL comment.
+type lexer struct {
+	src    string
+	pos    int
+	start  int
+	tokens []token
+	// backslash is true to take \ in '...' and "..." as an escape character like MySQL
+	backslash bool
+	// unterminated is set when a quoted string is not terminated
+	unterminated bool
 }
 
 // tokenizeは文字列を受け取ってトークンの列を返す
+// クォートが閉じていない場合は、MySQLのようにバックスラッシュをエスケープとして再度トークン化する
 func tokenize(str string) ([]token, error) {
-	var tokens []token
-
-	index := 0
-	start := 0
-	length := len(str)
-	//index out of boundsを避けるため末尾に空白を追加する。
-	str = str + "    "
-
-	for index < length {
-		if str[index:index+2] == "/*" {
-			if str[index:index+3] == "/*+" {
-				// hint句の場合はskipする
-				index++
-				continue
-			}
-			//コメントの直前の塊をTKSQLStmtとしてappend
-			tokens = append(tokens, token{
-				kind: tkSQLStmt,
-				str:  str[start:index],
-			})
-			start = index
-			index += 2
-			tok := token{}
-			for index < length && str[index:index+2] != "*/" {
-				if str[index:index+2] == "IF" {
-					tok.kind = tkIf
-					index += 2
-					continue
-				}
-				if str[index:index+4] == "ELIF" {
-					tok.kind = tkElif
-					index += 4
-					continue
-				}
-				if str[index:index+4] == "ELSE" {
-					tok.kind = tkElse
-					index += 4
-					continue
-				}
-				if str[index:index+3] == "END" {
-					tok.kind = tkEnd
-					index += 3
-					continue
+	tokens, unterminated, err := tokenizeMode(str, false)
+	if err != nil && unterminated {
+		if retried, _, retryErr := tokenizeMode(str, true); retryErr == nil {
+			return retried, nil
+		}
+	}
+	return tokens, err
+}
+
+func tokenizeMode(str string, backslash bool) ([]token, bool, error) {
+	l := &lexer{src: str, backslash: backslash}
+	tokens, err := l.run()
+	return tokens, l.unterminated, err
+}
+
+func (l *lexer) run() ([]token, error) {
+	for l.pos < len(l.src) {
+		var err error
+		switch c := l.src[l.pos]; {
+		case strings.HasPrefix(l.src[l.pos:], "/*+"):
+			// hint句の場合はskipする
+			err = l.skipComment()
+		case strings.HasPrefix(l.src[l.pos:], "/*"):
+			err = l.comment()
+		case strings.HasPrefix(l.src[l.pos:], "--"):
+			l.skipLineComment()
+		case c == '\'' || c == '"' || c == '`':
+			err = l.skipQuoted(c)
+		case c == '$':
+			err = l.skipDollarQuoted()
+		default:
+			l.pos++
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if l.start < len(l.src) {
+		l.tokens = append(l.tokens, token{
+			kind: tkSQLStmt,
+			str:  l.src[l.start:],
+			pos:  l.start,
+		})
+	}
+
+	// 処理しやすいように終点Tokenを付与する
+	l.tokens = append(l.tokens, token{
+		kind: tkEndOfProgram,
+		pos:  len(l.src),
+	})
+
+	return l.tokens, nil
+}
+
+// comment reads /* ... */ and the following literal of Bind
+func (l *lexer) comment() error {
+	//コメントの直前の塊をTKSQLStmtとしてappend
+	l.tokens = append(l.tokens, token{
+		kind: tkSQLStmt,
+		str:  l.src[l.start:l.pos],
+		pos:  l.start,
+	})
+	l.start = l.pos
+	end := strings.Index(l.src[l.pos+2:], "*/")
+	// */がなければ不正なフォーマット
+	if end == -1 {
+		return newSyntaxError(l.pos, restOfLine(l.src, l.pos), "Comment enclosing characters do not match", "*/")
+	}
+	content := l.src[l.pos+2 : l.pos+2+end]
+	l.pos += end + 4
+
+	tok := token{pos: l.start, kind: directive(content)}
+	if tok.kind == 0 {
+		tok.kind = tkBind
+		if err := l.bindLiteral(); err != nil {
+			return err
+		}
+	}
+
+	var err error
+	tok.str = l.src[l.start:l.pos]
+	switch tok.kind {
+	case tkIf, tkElif:
+		tok.condition = retrieveCondition(tok.kind, tok.str)
+	case tkFor:
+		tok.value, tok.condition, err = retrieveLoop(tok.str)
+		if err != nil {
+			return newSyntaxError(tok.pos, tok.str, err.Error(), "IN")
+		}
+	case tkSeparator:
+		tok.value = retrieveSeparator(tok.str)
+	case tkBind:
+		tok.str = bindLiteral(tok.str)
+		tok.value, tok.columns = retrieveColumns(retrieveValue(tok.str))
+		if strings.HasPrefix(tok.value, "$") {
+			// /*$value*/literal は値を直接埋め込む
+			tok.kind = tkRaw
+			tok.str = strings.TrimPrefix(tok.str, "?")
+			tok.value = strings.TrimSpace(strings.TrimPrefix(tok.value, "$"))
+		}
+	}
+	l.start = l.pos
+	l.tokens = append(l.tokens, tok)
+	return nil
+}
+
+// directive returns the kind of the keyword at the beginning of the comment, or 0 for Bind
+func directive(content string) tokenKind {
+	keyword, _ := splitDirective(content)
+	return directives[keyword]
+}
+
+// splitDirective splits the content of a comment into the first word and the rest.
+// Spaces around them are trimmed.
+func splitDirective(content string) (string, string) {
+	content = strings.TrimSpace(content)
+	end := strings.IndexFunc(content, func(r rune) bool {
+		return !isIdentRune(r)
+	})
+	if end == -1 {
+		return content, ""
+	}
+	return content[:end], strings.TrimSpace(content[end:])
+}
+
+// bindLiteral skips the dummy literal after /*value*/ like 1000, 'Jeff' or (1, 2, 3)
+func (l *lexer) bindLiteral() error {
+	if l.pos >= len(l.src) {
+		return nil
+	}
+	switch c := l.src[l.pos]; c {
+	case '(':
+		// /* ... */( ... ) or /* ... */( (...), (...) )
+		open := l.pos
+		depth := 0
+		for l.pos < len(l.src) {
+			switch l.src[l.pos] {
+			case '(':
+				depth++
+			case ')':
+				depth--
+			case '\'', '"':
+				if err := l.skipQuoted(l.src[l.pos]); err != nil {
+					return err
 				}
-				index++
-			}
-			// */がなければ不正なフォーマット
-			if str[index:index+2] != "*/" {
-				return []token{}, errors.New("Comment enclosing characters do not match")
+				continue
 			}
-			index += 2
-			if tok.kind == 0 {
-				tok.kind = tkBind
-				if quote := str[index]; quote == '(' {
-					// /* ... */( ... ) or /* ... */( (...), (...) )
-					var quoteStack []interface{}
-					index++
-					for index < length {
-						if str[index] == '(' {
-							quoteStack = append(quoteStack, struct{}{})
-							index++
-							continue
-						}
-						if str[index] == ')' {
-							if len(quoteStack) == 0 {
-								break
-							}
-							quoteStack = quoteStack[0 : len(quoteStack)-1]
-							index++
-							continue
-						}
-						index++
-					}
-					if str[index] != ')' {
-						return nil, errors.New("Enclosing characters do not match")
-					}
-					index++
-				} else if quote := str[index]; quote == '\'' || quote == '"' {
-					// /* ... */"..."
-					// /* ... */'...'
-					// 文字列が続いている。
-					// 実装汚い...
-					index++
-					for index < length && str[index] != quote {
-						index++
-					}
-					if str[index] != quote {
-						return nil, errors.New("Enclosing characters do not match")
-					}
-					index++
-				} else {
-					for index < length && str[index] != '\t' && str[index] != '\n' && str[index] != ' ' && str[index] != ',' && str[index] != ')' {
-						index++
-					}
-				}
+			l.pos++
+			if depth == 0 {
+				return nil
 			}
+		}
+		return newSyntaxError(open, restOfLine(l.src, open), "Enclosing characters do not match", ")")
+	case '\'', '"':
+		// /* ... */"..."
+		// /* ... */'...'
+		open := l.pos
+		if err := l.skipQuoted(c); err != nil {
+			return newSyntaxError(open, restOfLine(l.src, open), "Enclosing characters do not match", string(c))
+		}
+		return nil
+	default:
+		for l.pos < len(l.src) && !strings.ContainsRune("\t\n ,);", rune(l.src[l.pos])) {
+			l.pos++
+		}
+		return nil
+	}
+}
 
-			tok.str = str[start:index]
-			switch tok.kind {
-			case tkIf, tkElif:
-				tok.condition = retrieveCondition(tok.kind, tok.str)
-			case tkBind:
-				tok.str = bindLiteral(tok.str)
-				tok.value = retrieveValue(tok.str)
+// skipQuoted skips '...', "..." and `...`. A doubled quote in them is an escaped quote.
+// In the backslash mode, \ escapes the next character in '...' and "..." too.
+func (l *lexer) skipQuoted(quote byte) error {
+	open := l.pos
+	l.pos++
+	for l.pos < len(l.src) {
+		if l.backslash && quote != '`' && l.src[l.pos] == '\\' {
+			l.pos += 2
+			continue
+		}
+		if l.src[l.pos] == quote {
+			l.pos++
+			if l.pos < len(l.src) && l.src[l.pos] == quote {
+				l.pos++
+				continue
 			}
-			start = index
-			tokens = append(tokens, tok)
+			return nil
 		}
-		if index == length-1 {
-			tokens = append(tokens, token{
-				kind: tkSQLStmt,
-				str:  str[start : index+1],
-			})
+		l.pos++
+	}
+	l.unterminated = true
+	return newSyntaxError(open, restOfLine(l.src, open), "quoted string is not terminated", string(quote))
+}
+
+// skipDollarQuoted skips dollar quoted string of PostgreSQL like $$...$$ or $body$...$body$.
+// $ not starting a dollar quote like $1 is skipped as a character.
+func (l *lexer) skipDollarQuoted() error {
+	open := l.pos
+	if open > 0 && isIdentRune(rune(l.src[open-1])) {
+		l.pos++
+		return nil
+	}
+	end := strings.IndexByte(l.src[open+1:], '$')
+	if end == -1 {
+		l.pos++
+		return nil
+	}
+	tag := l.src[open : open+end+2]
+	for i, r := range tag[1 : len(tag)-1] {
+		if !isIdentRune(r) || (i == 0 && unicode.IsDigit(r)) {
+			l.pos++
+			return nil
 		}
-		index++
 	}
+	closing := strings.Index(l.src[open+len(tag):], tag)
+	if closing == -1 {
+		return newSyntaxError(open, restOfLine(l.src, open), "dollar quoted string is not terminated", tag)
+	}
+	l.pos = open + len(tag) + closing + len(tag)
+	return nil
+}
 
-	// 処理しやすいように終点Tokenを付与する
-	tokens = append(tokens, token{
-		kind: tkEndOfProgram,
-	})
+// skipLineComment skips -- comment until the end of the line
+func (l *lexer) skipLineComment() {
+	if end := strings.IndexByte(l.src[l.pos:], '\n'); end != -1 {
+		l.pos += end
+	} else {
+		l.pos = len(l.src)
+	}
+}
+
+// skipComment skips /* ... */ that is kept in the query like hints
+func (l *lexer) skipComment() error {
+	end := strings.Index(l.src[l.pos+2:], "*/")
+	if end == -1 {
+		return newSyntaxError(l.pos, restOfLine(l.src, l.pos), "Comment enclosing characters do not match", "*/")
+	}
+	l.pos += end + 4
+	return nil
+}
 
-	return tokens, nil
+func isIdentRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
 }
 
 // ?/*value*/から value1を取り出す
@@ -171,19 +360,39 @@ func bindLiteral(str string) string {
 // /* (IF|ELIF) condition */ -> conditionを返す
 // kind must be tkIf or tkElif
 func retrieveCondition(kind tokenKind, str string) string {
-	str = removeCommentSymbol(str)
-	str = strings.Trim(str, " ")
-	str = strings.Trim(str, "\n")
-	str = strings.Trim(str, "\t")
-	switch kind {
-	case tkIf:
-		str = strings.TrimPrefix(str, "IF")
-	case tkElif:
-		str = strings.TrimPrefix(str, "ELIF")
-	default:
+	if kind != tkIf && kind != tkElif {
 		panic("kind must be tKIF or tkElif")
 	}
-	return strings.TrimLeft(str, " ")
+	_, condition := splitDirective(removeCommentSymbol(str))
+	return condition
+}
+
+// /* FOR item IN items */ -> item, itemsを返す
+func retrieveLoop(str string) (string, string, error) {
+	fields := strings.Fields(removeCommentSymbol(str))
+	if len(fields) != 4 || fields[0] != "FOR" || fields[2] != "IN" {
+		return "", "", fmt.Errorf("invalid FOR comment: %s (/* FOR item IN items */ is expected)", str)
+	}
+	return fields[1], fields[3], nil
+}
+
+// people(first_name, last_name) -> people, "first_name,last_name"を返す
+func retrieveColumns(str string) (string, string) {
+	open := strings.Index(str, "(")
+	if open == -1 || !strings.HasSuffix(str, ")") {
+		return str, ""
+	}
+	var columns []string
+	for _, c := range strings.Split(str[open+1:len(str)-1], ",") {
+		columns = append(columns, strings.TrimSpace(c))
+	}
+	return strings.TrimSpace(str[:open]), strings.Join(columns, ",")
+}
+
+// /* SEPARATOR OR */ -> ORを返す
+func retrieveSeparator(str string) string {
+	str = strings.TrimSpace(removeCommentSymbol(str))
+	return strings.TrimSpace(strings.TrimPrefix(str, "SEPARATOR"))
 }
 
 // input: /*value*/ -> output: value
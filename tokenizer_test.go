@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	gocmp "github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"gotest.tools/v3/assert"
 	"gotest.tools/v3/assert/cmp"
 )
@@ -367,6 +368,92 @@ func TestTokenize(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:  "table bind with column list",
+			input: `INSERT INTO person (first_name, last_name) VALUES /*people(first_name, last_name)*/('Jeff', 'Dean')`,
+			want: []token{
+				{
+					kind: tkSQLStmt,
+					str:  "INSERT INTO person (first_name, last_name) VALUES ",
+				},
+				{
+					kind:    tkBind,
+					str:     "?/*people(first_name, last_name)*/",
+					value:   "people",
+					columns: "first_name,last_name",
+				},
+				{
+					kind: tkEndOfProgram,
+				},
+			},
+		},
+		{
+			name:  "raw",
+			input: `SELECT * FROM person ORDER BY /*$sortColumn*/employee_no;`,
+			want: []token{
+				{
+					kind: tkSQLStmt,
+					str:  "SELECT * FROM person ORDER BY ",
+				},
+				{
+					kind:  tkRaw,
+					str:   "/*$sortColumn*/",
+					value: "sortColumn",
+				},
+				{
+					kind: tkSQLStmt,
+					str:  ";",
+				},
+				{
+					kind: tkEndOfProgram,
+				},
+			},
+		},
+		{
+			name:  "for",
+			input: `SELECT * FROM person WHERE /* FOR item IN filters */ name = /*item.name*/'Jeff' /* SEPARATOR OR */ /* END */`,
+			want: []token{
+				{
+					kind: tkSQLStmt,
+					str:  "SELECT * FROM person WHERE ",
+				},
+				{
+					kind:      tkFor,
+					str:       "/* FOR item IN filters */",
+					value:     "item",
+					condition: "filters",
+				},
+				{
+					kind: tkSQLStmt,
+					str:  " name = ",
+				},
+				{
+					kind:  tkBind,
+					str:   "?/*item.name*/",
+					value: "item.name",
+				},
+				{
+					kind: tkSQLStmt,
+					str:  " ",
+				},
+				{
+					kind:  tkSeparator,
+					str:   "/* SEPARATOR OR */",
+					value: "OR",
+				},
+				{
+					kind: tkSQLStmt,
+					str:  " ",
+				},
+				{
+					kind: tkEnd,
+					str:  "/* END */",
+				},
+				{
+					kind: tkEndOfProgram,
+				},
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -405,11 +492,11 @@ func TestTokenizeShouldReturnError(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if _, err := tokenize(tt.input); err == nil || err.Error() != tt.wantError {
+			if _, err := tokenize(tt.input); err == nil || syntaxErrorMsg(err) != tt.wantError {
 				if err == nil {
 					t.Error("Should Error")
-				} else if err.Error() != tt.wantError {
-					t.Errorf("Doesn't Match expected: %v, but got: %v\n", tt.wantError, err.Error())
+				} else if syntaxErrorMsg(err) != tt.wantError {
+					t.Errorf("Doesn't Match expected: %v, but got: %v\n", tt.wantError, syntaxErrorMsg(err))
 				}
 			}
 		})
@@ -1097,7 +1184,146 @@ WHERE
 		t.Run(tt.name, func(t *testing.T) {
 			got, err := tokenize(tt.input)
 			assert.NilError(t, err)
-			assert.Check(t, cmp.DeepEqual(tt.want, got, gocmp.AllowUnexported(token{})))
+			assert.Check(t, cmp.DeepEqual(tt.want, got, gocmp.AllowUnexported(token{}), cmpopts.IgnoreFields(token{}, "pos")))
+		})
+	}
+}
+
+func TestTokenize_Quoted(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []token
+	}{
+		{
+			name:  "comment in string literal",
+			input: `SELECT '/* not a comment */', 'It''s /* x */' FROM person WHERE id = /*id*/1`,
+			want: []token{
+				{kind: tkSQLStmt, str: `SELECT '/* not a comment */', 'It''s /* x */' FROM person WHERE id = `},
+				{kind: tkBind, str: "?/*id*/", value: "id"},
+				{kind: tkEndOfProgram},
+			},
+		},
+		{
+			name:  "comment in quoted identifier",
+			input: "SELECT \"a/*b*/\", `c/*d*/` FROM person WHERE id = /*id*/1",
+			want: []token{
+				{kind: tkSQLStmt, str: "SELECT \"a/*b*/\", `c/*d*/` FROM person WHERE id = "},
+				{kind: tkBind, str: "?/*id*/", value: "id"},
+				{kind: tkEndOfProgram},
+			},
+		},
+		{
+			name:  "comment in line comment",
+			input: "SELECT * FROM person -- see /* IF */\nWHERE id = /*id*/1",
+			want: []token{
+				{kind: tkSQLStmt, str: "SELECT * FROM person -- see /* IF */\nWHERE id = "},
+				{kind: tkBind, str: "?/*id*/", value: "id"},
+				{kind: tkEndOfProgram},
+			},
+		},
+		{
+			name:  "comment in dollar quoted string",
+			input: "SELECT $$/* END */$$, $tag$ /* IF */ $$ $tag$ FROM person WHERE id = $1 AND no = /*no*/1",
+			want: []token{
+				{kind: tkSQLStmt, str: "SELECT $$/* END */$$, $tag$ /* IF */ $$ $tag$ FROM person WHERE id = $1 AND no = "},
+				{kind: tkBind, str: "?/*no*/", value: "no"},
+				{kind: tkEndOfProgram},
+			},
+		},
+		{
+			name:  "keyword is not leading",
+			input: "SELECT * FROM person WHERE url = /*ENDpoint*/'x' /* IF mode == 'ELSE' */ AND a = 1 /* END */",
+			want: []token{
+				{kind: tkSQLStmt, str: "SELECT * FROM person WHERE url = "},
+				{kind: tkBind, str: "?/*ENDpoint*/", value: "ENDpoint"},
+				{kind: tkSQLStmt, str: " "},
+				{kind: tkIf, str: "/* IF mode == 'ELSE' */", condition: "mode == 'ELSE'"},
+				{kind: tkSQLStmt, str: " AND a = 1 "},
+				{kind: tkEnd, str: "/* END */"},
+				{kind: tkEndOfProgram},
+			},
+		},
+		{
+			name:  "backslash escaped quote",
+			input: `SELECT * FROM t WHERE name = 'O\'Reilly' AND note = "a\"/* b */" AND id = /*id*/1`,
+			want: []token{
+				{kind: tkSQLStmt, str: `SELECT * FROM t WHERE name = 'O\'Reilly' AND note = "a\"/* b */" AND id = `},
+				{kind: tkBind, str: "?/*id*/", value: "id"},
+				{kind: tkEndOfProgram},
+			},
+		},
+		{
+			name:  "backslash in standard string",
+			input: `SELECT * FROM t WHERE path = 'C:\' AND id = /*id*/1`,
+			want: []token{
+				{kind: tkSQLStmt, str: `SELECT * FROM t WHERE path = 'C:\' AND id = `},
+				{kind: tkBind, str: "?/*id*/", value: "id"},
+				{kind: tkEndOfProgram},
+			},
+		},
+		{
+			name:  "directive after newline",
+			input: "SELECT * FROM t /*\n  IF id != null */ WHERE id = /*id*/1 /*\tEND\n*/",
+			want: []token{
+				{kind: tkSQLStmt, str: "SELECT * FROM t "},
+				{kind: tkIf, str: "/*\n  IF id != null */", condition: "id != null"},
+				{kind: tkSQLStmt, str: " WHERE id = "},
+				{kind: tkBind, str: "?/*id*/", value: "id"},
+				{kind: tkSQLStmt, str: " "},
+				{kind: tkEnd, str: "/*\tEND\n*/"},
+				{kind: tkEndOfProgram},
+			},
+		},
+		{
+			name:  "parenthesis in string of bind literal",
+			input: "SELECT * FROM person WHERE name IN /*names*/('a)', 'b') AND no = /*no*/1",
+			want: []token{
+				{kind: tkSQLStmt, str: "SELECT * FROM person WHERE name IN "},
+				{kind: tkBind, str: "?/*names*/", value: "names"},
+				{kind: tkSQLStmt, str: " AND no = "},
+				{kind: tkBind, str: "?/*no*/", value: "no"},
+				{kind: tkEndOfProgram},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tokenize(tt.input)
+			assert.NilError(t, err)
+			assert.Check(t, cmp.DeepEqual(tt.want, got, gocmp.AllowUnexported(token{}), cmpopts.IgnoreFields(token{}, "pos")))
+		})
+	}
+}
+
+func TestTokenize_NotTerminated(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		wantError string
+	}{
+		{
+			name:      "string literal",
+			input:     "SELECT * FROM person WHERE name = 'Jeff AND id = /*id*/1",
+			wantError: "quoted string is not terminated",
+		},
+		{
+			name:      "dollar quoted string",
+			input:     "SELECT $body$ /*id*/1 FROM person",
+			wantError: "dollar quoted string is not terminated",
+		},
+		{
+			name:      "hint",
+			input:     "SELECT /*+ INDEX(person) FROM person",
+			wantError: "Comment enclosing characters do not match",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tokenize(tt.input)
+			assert.Equal(t, tt.wantError, syntaxErrorMsg(err))
 		})
 	}
 }
@@ -1107,7 +1333,10 @@ func tokensEqual(want, got []token) bool {
 		return false
 	}
 	for i := 0; i < len(want); i++ {
-		if want[i] != got[i] {
+		// positions are tested in TestSyntaxError
+		w, g := want[i], got[i]
+		w.pos, g.pos = 0, 0
+		if w != g {
 			return false
 		}
 	}
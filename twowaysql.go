@@ -9,15 +9,33 @@ import (
 	"github.com/jmoiron/sqlx"
 )
 
+// Querier runs precompiled templates. It is implemented by Twowaysql and TwowaysqlTx,
+// so functions generated by "twowaysql generate go" can be used in and out of transactions.
+type Querier interface {
+	SelectTemplate(ctx context.Context, dest interface{}, tmpl *Template, params interface{}) error
+	ExecTemplate(ctx context.Context, tmpl *Template, params interface{}) (sql.Result, error)
+	GetTemplate(ctx context.Context, dest interface{}, tmpl *Template, params interface{}) error
+}
+
+var (
+	_ Querier = (*Twowaysql)(nil)
+	_ Querier = (*TwowaysqlTx)(nil)
+)
+
 // Twowaysql is a struct for issuing 2WaySQL query
 type Twowaysql struct {
-	db *sqlx.DB
+	db    *sqlx.DB
+	opts  []Option
+	hooks []Hook
 }
 
 // New returns instance of Twowaysql
-func New(db *sqlx.DB) *Twowaysql {
+// opts are used to compile queries passed as string. Hooks registered by WithHooks are called around all queries.
+func New(db *sqlx.DB, opts ...Option) *Twowaysql {
 	return &Twowaysql{
-		db: db,
+		db:    db,
+		opts:  opts,
+		hooks: newOptions(opts).hooks,
 	}
 }
 
@@ -25,37 +43,62 @@ func New(db *sqlx.DB) *Twowaysql {
 // params takes a tagged struct. The tags format must be `twowaysql:"tag_name"`.
 // dest takes a pointer to a slice of a struct. The struct tag format must be `db:"tag_name"`.
 func (t *Twowaysql) Select(ctx context.Context, dest interface{}, query string, params interface{}) error {
-	eval, bindParams, err := Eval(query, params)
+	tmpl, err := Compile(query, t.opts...)
 	if err != nil {
-		return err
+		return reportError(ctx, t.hooks, "Select", t.db.DriverName(), nil, err)
 	}
+	return t.SelectTemplate(ctx, dest, tmpl, params)
+}
 
-	q := t.db.Rebind(eval)
-
-	if destMap, ok := dest.(*[]map[string]interface{}); ok {
-		rows, err := t.db.QueryxContext(ctx, q, bindParams...)
-		if err != nil {
-			return err
-		}
-		return convertResultToMap(destMap, rows)
+// SelectTemplate is like Select but takes a precompiled template.
+func (t *Twowaysql) SelectTemplate(ctx context.Context, dest interface{}, tmpl *Template, params interface{}) error {
+	eval, bindParams, redacted, err := tmpl.eval(ctx, params)
+	if err != nil {
+		return reportError(ctx, t.hooks, "Select", t.db.DriverName(), tmpl, err)
 	}
 
-	return t.db.SelectContext(ctx, dest, q, bindParams...)
+	q := tmpl.rebind(eval, t.db.Rebind)
 
+	event := newQueryEvent("Select", t.db.DriverName(), tmpl, q, bindParams, redacted)
+	return runHooks(ctx, t.hooks, event, func(ctx context.Context) error {
+		if destMap, ok := dest.(*[]map[string]interface{}); ok {
+			rows, err := t.db.QueryxContext(ctx, q, bindParams...)
+			if err != nil {
+				return redactError(err, bindParams, redacted)
+			}
+			return redactError(convertResultToMap(destMap, rows), bindParams, redacted)
+		}
+
+		return redactError(t.db.SelectContext(ctx, dest, q, bindParams...), bindParams, redacted)
+	})
 }
 
 // Exec is a thin wrapper around db.Exec in the sqlx package.
 // params takes a tagged struct. The tags format must be `twowaysql:"tag_name"`.
 func (t *Twowaysql) Exec(ctx context.Context, query string, params interface{}) (sql.Result, error) {
+	tmpl, err := Compile(query, t.opts...)
+	if err != nil {
+		return nil, reportError(ctx, t.hooks, "Exec", t.db.DriverName(), nil, err)
+	}
+	return t.ExecTemplate(ctx, tmpl, params)
+}
 
-	eval, bindParams, err := Eval(query, params)
+// ExecTemplate is like Exec but takes a precompiled template.
+func (t *Twowaysql) ExecTemplate(ctx context.Context, tmpl *Template, params interface{}) (sql.Result, error) {
+	eval, bindParams, redacted, err := tmpl.eval(ctx, params)
 	if err != nil {
-		return nil, err
+		return nil, reportError(ctx, t.hooks, "Exec", t.db.DriverName(), tmpl, err)
 	}
 
-	q := t.db.Rebind(eval)
+	q := tmpl.rebind(eval, t.db.Rebind)
 
-	return t.db.ExecContext(ctx, q, bindParams...)
+	event := newQueryEvent("Exec", t.db.DriverName(), tmpl, q, bindParams, redacted)
+	err = runHooks(ctx, t.hooks, event, func(ctx context.Context) error {
+		var err error
+		event.Result, err = t.db.ExecContext(ctx, q, bindParams...)
+		return redactError(err, bindParams, redacted)
+	})
+	return event.Result, err
 }
 
 // Begin is a thin wrapper around db.BeginTxx in the sqlx package.
@@ -66,7 +109,7 @@ func (t *Twowaysql) Begin(ctx context.Context) (*TwowaysqlTx, error) {
 		return nil, err
 	}
 
-	return &TwowaysqlTx{tx: tx}, nil
+	return &TwowaysqlTx{tx: tx, opts: t.opts, hooks: t.hooks}, nil
 }
 
 // Close is a thin wrapper around db.Close in the sqlx package.
@@ -116,7 +159,9 @@ func (t *Twowaysql) Transaction(ctx context.Context, fn func(tx *TwowaysqlTx) er
 
 // TwowaysqlTx is a structure for issuing 2WaySQL queries within a transaction.
 type TwowaysqlTx struct {
-	tx *sqlx.Tx
+	tx    *sqlx.Tx
+	opts  []Option
+	hooks []Hook
 }
 
 // Commit is a thin wrapper around tx.Commit in the sqlx package.
@@ -144,39 +189,65 @@ func (t *TwowaysqlTx) Rollback() error {
 // dest takes a pointer to a slice of a struct. The struct tag format must be `db:"tag_name"`.
 // It is an equivalent implementation of Twowaysql.Select
 func (t *TwowaysqlTx) Select(ctx context.Context, dest interface{}, query string, params interface{}) error {
+	tmpl, err := Compile(query, t.opts...)
+	if err != nil {
+		return reportError(ctx, t.hooks, "Select", t.tx.DriverName(), nil, err)
+	}
+	return t.SelectTemplate(ctx, dest, tmpl, params)
+}
 
-	eval, bindParams, err := Eval(query, params)
+// SelectTemplate is like Select but takes a precompiled template.
+// It is an equivalent implementation of Twowaysql.SelectTemplate
+func (t *TwowaysqlTx) SelectTemplate(ctx context.Context, dest interface{}, tmpl *Template, params interface{}) error {
+	eval, bindParams, redacted, err := tmpl.eval(ctx, params)
 	if err != nil {
-		return err
+		return reportError(ctx, t.hooks, "Select", t.tx.DriverName(), tmpl, err)
 	}
 
-	q := t.tx.Rebind(eval)
+	q := tmpl.rebind(eval, t.tx.Rebind)
 
-	if destMap, ok := dest.(*[]map[string]interface{}); ok {
-		rows, err := t.tx.QueryxContext(ctx, q, bindParams...)
-		if err != nil {
-			return err
+	event := newQueryEvent("Select", t.tx.DriverName(), tmpl, q, bindParams, redacted)
+	return runHooks(ctx, t.hooks, event, func(ctx context.Context) error {
+		if destMap, ok := dest.(*[]map[string]interface{}); ok {
+			rows, err := t.tx.QueryxContext(ctx, q, bindParams...)
+			if err != nil {
+				return redactError(err, bindParams, redacted)
+			}
+			return redactError(convertResultToMap(destMap, rows), bindParams, redacted)
 		}
-		return convertResultToMap(destMap, rows)
-	}
-
-	return t.tx.SelectContext(ctx, dest, q, bindParams...)
 
+		return redactError(t.tx.SelectContext(ctx, dest, q, bindParams...), bindParams, redacted)
+	})
 }
 
 // Exec is a thin wrapper around db.Exec in the sqlx package.
 // params takes a tagged struct. The tags format must be `twowaysql:"tag_name"`.
 // It is an equivalent implementation of Twowaysql.Exec
 func (t *TwowaysqlTx) Exec(ctx context.Context, query string, params interface{}) (sql.Result, error) {
+	tmpl, err := Compile(query, t.opts...)
+	if err != nil {
+		return nil, reportError(ctx, t.hooks, "Exec", t.tx.DriverName(), nil, err)
+	}
+	return t.ExecTemplate(ctx, tmpl, params)
+}
 
-	eval, bindParams, err := Eval(query, params)
+// ExecTemplate is like Exec but takes a precompiled template.
+// It is an equivalent implementation of Twowaysql.ExecTemplate
+func (t *TwowaysqlTx) ExecTemplate(ctx context.Context, tmpl *Template, params interface{}) (sql.Result, error) {
+	eval, bindParams, redacted, err := tmpl.eval(ctx, params)
 	if err != nil {
-		return nil, err
+		return nil, reportError(ctx, t.hooks, "Exec", t.tx.DriverName(), tmpl, err)
 	}
 
-	q := t.tx.Rebind(eval)
+	q := tmpl.rebind(eval, t.tx.Rebind)
 
-	return t.tx.ExecContext(ctx, q, bindParams...)
+	event := newQueryEvent("Exec", t.tx.DriverName(), tmpl, q, bindParams, redacted)
+	err = runHooks(ctx, t.hooks, event, func(ctx context.Context) error {
+		var err error
+		event.Result, err = t.tx.ExecContext(ctx, q, bindParams...)
+		return redactError(err, bindParams, redacted)
+	})
+	return event.Result, err
 }
 
 func convertResultToMap(dest *[]map[string]interface{}, rows *sqlx.Rows) error {
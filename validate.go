@@ -0,0 +1,122 @@
+package twowaysql
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ValidationError is a problem of a Markdown document found by Document.Validate.
+type ValidationError struct {
+	// Line is the line number in the Markdown file. It is 0 if unknown.
+	Line int
+	// Msg is the message without the position.
+	Msg string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Line == 0 {
+		return e.Msg
+	}
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+// Validate checks the parameter table against the SQL and the test cases. It reports
+//
+//   - syntax errors of the SQL
+//   - parameters that are used in the SQL but not declared in the table
+//   - parameters that are declared in the table but not used in the SQL
+//   - test cases that have params not declared in the table
+//   - values that can't be parsed as the type of the parameter
+//
+// It returns nil if there is no problem.
+func (d *Document) Validate() []*ValidationError {
+	var result []*ValidationError
+	add := func(line int, format string, args ...any) {
+		result = append(result, &ValidationError{Line: line, Msg: fmt.Sprintf(format, args...)})
+	}
+
+	declared := make(map[string]Param, len(d.Params))
+	for _, p := range d.Params {
+		declared[p.Name] = p
+		if p.Value != "" {
+			if _, err := p.Type.Convert(p.Value); err != nil {
+				add(p.Line, "parameter '%s': %s", p.Name, err)
+			}
+		}
+	}
+
+	analysis, err := Analyze(d.SQL, append(d.Options(), WithSource("", d.SQLLine))...)
+	if err != nil {
+		var syntaxErr *SyntaxError
+		if errors.As(err, &syntaxErr) {
+			add(syntaxErr.Line, "%s", syntaxErr.Msg)
+		} else {
+			add(d.SQLLine, "%s", err)
+		}
+	} else {
+		lines := paramLines(analysis)
+		used := make(map[string]bool, len(analysis.Params))
+		for _, name := range analysis.Params {
+			used[name] = true
+			if _, ok := declared[name]; !ok {
+				add(lines[name], "parameter '%s' is used in SQL but not declared", name)
+			}
+		}
+		for _, p := range d.Params {
+			if !used[p.Name] {
+				add(p.Line, "parameter '%s' is declared but not used in SQL", p.Name)
+			}
+		}
+	}
+
+	for _, tc := range d.TestCases {
+		names := make([]string, 0, len(tc.Params))
+		for name := range tc.Params {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			p, ok := declared[name]
+			if !ok {
+				add(tc.Line, "test case '%s': parameter '%s' is not declared", tc.Name, name)
+				continue
+			}
+			if _, err := p.Type.Convert(tc.Params[name]); err != nil {
+				add(tc.Line, "test case '%s': parameter '%s': %s", tc.Name, name, err)
+			}
+		}
+	}
+	return result
+}
+
+// paramLines returns the line where each parameter is used first
+func paramLines(a *Analysis) map[string]int {
+	result := make(map[string]int)
+	set := func(name string, pos Position) {
+		if line, ok := result[name]; !ok || pos.Line < line {
+			result[name] = pos.Line
+		}
+	}
+	for _, b := range a.Binds {
+		set(b.Param, b.Pos)
+	}
+	for _, c := range a.Conditions {
+		for _, p := range c.Params {
+			set(p, c.Pos)
+		}
+	}
+	var walk func(blocks []*Block)
+	walk = func(blocks []*Block) {
+		for _, b := range blocks {
+			if b.Kind == "FOR" {
+				root, _, _ := strings.Cut(b.Condition, ".")
+				set(root, b.Pos)
+			}
+			walk(b.Children)
+		}
+	}
+	walk(a.Blocks)
+	return result
+}
@@ -0,0 +1,131 @@
+package twowaysql
+
+import (
+	"testing"
+
+	"github.com/future-architect/go-twowaysql/private/testhelper"
+	"gotest.tools/v3/assert"
+)
+
+func TestDocument_Validate(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []string
+	}{
+		{
+			name: "valid",
+			src: testhelper.TrimIndent(t, `
+			# Search Users
+
+			~~~sql
+			SELECT * FROM persons
+			WHERE /* IF age */age > /*age*/20 /* END */
+			/* IF since */AND created_at > /*since*/'2020-01-01'/* END */
+			~~~
+
+			## Parameters
+
+			| Name  | Type      | Description |
+			|-------|-----------|-------------|
+			| age   | int       | age         |
+			| since | timestamp | since       |
+
+			## Test
+
+			### Case: search
+
+			~~~yaml
+			params: { age: 30, since: 2021-04-01 }
+			expect: []
+			~~~
+			`),
+		},
+		{
+			name: "undeclared and unused",
+			src: testhelper.TrimIndent(t, `
+			# Search Users
+
+			~~~sql
+			SELECT * FROM persons
+			WHERE first_name = /*fist_name*/'bob'
+			/* FOR dept IN depts */OR dept_no = /*dept.no*/1 /* END */
+			~~~
+
+			## Parameters
+
+			| Name       | Type | Description |
+			|------------|------|-------------|
+			| first_name | text | first name  |
+			`),
+			want: []string{
+				"line 5: parameter 'fist_name' is used in SQL but not declared",
+				"line 6: parameter 'depts' is used in SQL but not declared",
+				"line 13: parameter 'first_name' is declared but not used in SQL",
+			},
+		},
+		{
+			name: "test case params",
+			src: testhelper.TrimIndent(t, `
+			# Search Users
+
+			~~~sql
+			SELECT * FROM persons WHERE age > /*age*/20 AND active = /*active*/true
+			~~~
+
+			## Parameters
+
+			| Name   | Type | Description |
+			|--------|------|-------------|
+			| age    | int  | age         |
+			| active | bool | active      |
+
+			## Test
+
+			### Case: ok
+
+			~~~yaml
+			params: { age: 30, active: true }
+			expect: []
+			~~~
+
+			### Case: ng
+
+			~~~yaml
+			params: { age: thirty, active: yes, name: bob }
+			expect: []
+			~~~
+			`),
+			want: []string{
+				"line 23: test case 'ng': parameter 'active': 'yes' is not a valid bool value",
+				"line 23: test case 'ng': parameter 'age': 'thirty' is not a valid integer value",
+				"line 23: test case 'ng': parameter 'name' is not declared",
+			},
+		},
+		{
+			name: "syntax error",
+			src: testhelper.TrimIndent(t, `
+			# Search Users
+
+			~~~sql
+			SELECT * FROM persons
+			WHERE /* IF age */age > /*age*/20
+			~~~
+			`),
+			want: []string{
+				"line 5: can not parse: not found /* END */",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := ParseMarkdownString(tt.src)
+			assert.NilError(t, err)
+			var got []string
+			for _, e := range doc.Validate() {
+				got = append(got, e.Error())
+			}
+			assert.DeepEqual(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,409 @@
+package twowaysql
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// normalizeValue dereferences pointers and interfaces, and converts typed nil to untyped nil.
+func normalizeValue(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+		if rv.IsNil() {
+			return nil
+		}
+	}
+	return rv.Interface()
+}
+
+// isTruthy returns the truth value of v in the same manner as JavaScript.
+// nil, false, 0, NaN and empty string are falsy. Others are truthy.
+func isTruthy(v interface{}) bool {
+	v = normalizeValue(v)
+	if v == nil {
+		return false
+	}
+	if d, ok := v.(Decimal); ok {
+		f, _ := toNumber(d)
+		return f != 0
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Bool:
+		return rv.Bool()
+	case reflect.String:
+		return rv.Len() != 0
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int() != 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return rv.Uint() != 0
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		return f != 0 && !math.IsNaN(f)
+	}
+	return true
+}
+
+// toNumber converts numeric value to float64. Decimal is also a number.
+func toNumber(v interface{}) (float64, bool) {
+	if d, ok := v.(Decimal); ok {
+		f, err := strconv.ParseFloat(string(d), 64)
+		return f, err == nil
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+// valueEqual compares two values strictly like === of JavaScript.
+// Numbers are compared by value regardless of their types.
+func valueEqual(a, b interface{}) bool {
+	a = normalizeValue(a)
+	b = normalizeValue(b)
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if c, ok := compareValues(a, b); ok {
+		return c == 0
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// looseEqual compares two values like == of JavaScript.
+// If the values are a number, a string or a boolean of different kinds, both are converted to numbers,
+// so "30" == 30 and true == 1 are true. Other values are compared by valueEqual.
+func looseEqual(a, b interface{}) bool {
+	a = normalizeValue(a)
+	b = normalizeValue(b)
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	ka, kb := primitiveKind(a), primitiveKind(b)
+	if ka != kb && ka != reflect.Invalid && kb != reflect.Invalid {
+		an, _ := looseNumber(a)
+		bn, _ := looseNumber(b)
+		return an == bn
+	}
+	return valueEqual(a, b)
+}
+
+// looseCompare compares two values like relational operators of JavaScript.
+// Two strings are compared as strings, and two times are compared as times.
+// Otherwise both values are converted to numbers and ok is false if either is NaN.
+// It returns an error if the values can not be converted to numbers like slices, maps and structs.
+func looseCompare(a, b interface{}) (result int, ok bool, err error) {
+	a = normalizeValue(a)
+	b = normalizeValue(b)
+	if a != nil && b != nil && primitiveKind(a) == primitiveKind(b) {
+		if result, ok := compareValues(a, b); ok {
+			return result, true, nil
+		}
+	}
+	an, aok := looseNumber(a)
+	bn, bok := looseNumber(b)
+	if !aok || !bok {
+		return 0, false, fmt.Errorf("can not compare %s with %s", typeName(a), typeName(b))
+	}
+	switch {
+	case an < bn:
+		return -1, true, nil
+	case an > bn:
+		return 1, true, nil
+	case an == bn:
+		return 0, true, nil
+	}
+	// NaN
+	return 0, false, nil
+}
+
+// primitiveKind returns reflect.Float64 for numbers, reflect.String for strings and reflect.Bool for booleans.
+// Times are reported as reflect.Struct. It returns reflect.Invalid for other values.
+func primitiveKind(v interface{}) reflect.Kind {
+	if _, ok := toNumber(v); ok {
+		return reflect.Float64
+	}
+	if _, ok := v.(Decimal); ok {
+		return reflect.Float64
+	}
+	rv := reflect.ValueOf(v)
+	switch {
+	case rv.Kind() == reflect.String, rv.Kind() == reflect.Bool:
+		return rv.Kind()
+	case rv.Type() == timeType:
+		return reflect.Struct
+	}
+	return reflect.Invalid
+}
+
+// looseNumber converts v to a number like Number() of JavaScript.
+// null is 0, booleans are 0 or 1, and strings are parsed after trimming spaces. An empty string is 0 and
+// a non-numeric string is NaN. ok is false for values that are not converted to numbers like times and slices.
+func looseNumber(v interface{}) (float64, bool) {
+	if v == nil {
+		return 0, true
+	}
+	if f, ok := toNumber(v); ok {
+		return f, true
+	}
+	if _, ok := v.(Decimal); ok {
+		return math.NaN(), true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Bool:
+		if rv.Bool() {
+			return 1, true
+		}
+		return 0, true
+	case reflect.String:
+		s := strings.TrimSpace(rv.String())
+		if s == "" {
+			return 0, true
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return math.NaN(), true
+		}
+		return f, true
+	}
+	return 0, false
+}
+
+// typeName returns the name of the type for error messages like typeof of JavaScript
+func typeName(v interface{}) string {
+	if v == nil {
+		return "null"
+	}
+	switch primitiveKind(v) {
+	case reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// compareValues compares two numbers, strings or times.
+// ok is false if these values are not comparable.
+func compareValues(a, b interface{}) (result int, ok bool) {
+	a = normalizeValue(a)
+	b = normalizeValue(b)
+	if a == nil || b == nil {
+		return 0, false
+	}
+	if an, ok := toNumber(a); ok {
+		bn, ok := toNumber(b)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case an < bn:
+			return -1, true
+		case an > bn:
+			return 1, true
+		case an == bn:
+			return 0, true
+		}
+		// NaN
+		return 0, false
+	}
+	ra := reflect.ValueOf(a)
+	rb := reflect.ValueOf(b)
+	if ra.Kind() == reflect.String && rb.Kind() == reflect.String {
+		return strings.Compare(ra.String(), rb.String()), true
+	}
+	if ra.Kind() == reflect.Bool && rb.Kind() == reflect.Bool {
+		if ra.Bool() == rb.Bool() {
+			return 0, true
+		}
+		return 0, false
+	}
+	if ra.Type() == timeType && rb.Type() == timeType {
+		ta := ra.Interface().(time.Time)
+		tb := rb.Interface().(time.Time)
+		switch {
+		case ta.Before(tb):
+			return -1, true
+		case ta.After(tb):
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// valueLength returns length of string, slice, array and map.
+func valueLength(v interface{}) (int, error) {
+	v = normalizeValue(v)
+	if v == nil {
+		return 0, nil
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.String:
+		return utf8.RuneCountInString(rv.String()), nil
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return rv.Len(), nil
+	}
+	return 0, fmt.Errorf("len() is not supported for %T", v)
+}
+
+// valueContains returns true if collection contains elem.
+// Collection can be a slice, an array, a map (checks keys) or a string (checks substring).
+func valueContains(collection, elem interface{}) (bool, error) {
+	collection = normalizeValue(collection)
+	if collection == nil {
+		return false, nil
+	}
+	rv := reflect.ValueOf(collection)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			if valueEqual(rv.Index(i).Interface(), elem) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case reflect.Map:
+		for _, k := range rv.MapKeys() {
+			if valueEqual(k.Interface(), elem) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case reflect.String:
+		s, ok := normalizeValue(elem).(string)
+		if !ok {
+			return false, fmt.Errorf("'in' operator for string requires string, but got %T", elem)
+		}
+		return strings.Contains(rv.String(), s), nil
+	}
+	return false, fmt.Errorf("'in' operator is not supported for %T", collection)
+}
+
+// lookupParam returns a parameter. name can be a dotted path like user.address.city or items.0.name.
+// Unlike member access in conditions, a missing key of map is an error.
+func lookupParam(params map[string]interface{}, name string) (interface{}, error) {
+	if v, ok := params[name]; ok {
+		return checkAmbiguous(name, v)
+	}
+	root, rest, found := strings.Cut(name, ".")
+	v, ok := params[root]
+	if !found || !ok {
+		return nil, fmt.Errorf("no parameter that matches the bind value: %s", name)
+	}
+	v, err := checkAmbiguous(root, v)
+	if err != nil {
+		return nil, err
+	}
+	path := root
+	for _, segment := range strings.Split(rest, ".") {
+		if rv := reflect.ValueOf(normalizeValue(v)); rv.Kind() == reflect.Map && rv.Type().Key().Kind() == reflect.String {
+			if !rv.MapIndex(reflect.ValueOf(segment).Convert(rv.Type().Key())).IsValid() {
+				return nil, fmt.Errorf("can not resolve %s: %s has no key '%s'", name, path, segment)
+			}
+		}
+		v, err = memberValue(v, segment)
+		if err != nil {
+			return nil, fmt.Errorf("can not resolve %s: %w", name, err)
+		}
+		path += "." + segment
+	}
+	return v, nil
+}
+
+func checkAmbiguous(name string, v interface{}) (interface{}, error) {
+	if a, ok := v.(ambiguousParam); ok {
+		return nil, fmt.Errorf("parameter %s is ambiguous (%s). use dotted path instead", name, strings.Join(a.paths, ", "))
+	}
+	return v, nil
+}
+
+// memberValue returns a field of struct, an element of map, slice and array.
+// Struct fields are looked up by `twowaysql` tag, `db` tag and then field name.
+func memberValue(v interface{}, name string) (interface{}, error) {
+	v = normalizeValue(v)
+	if v == nil {
+		return nil, fmt.Errorf("can not access '%s' of null", name)
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("can not access '%s' of %T", name, v)
+		}
+		e := rv.MapIndex(reflect.ValueOf(name).Convert(rv.Type().Key()))
+		if !e.IsValid() {
+			return nil, nil
+		}
+		return e.Interface(), nil
+	case reflect.Struct:
+		if f, ok := structField(rv, name); ok {
+			return f.Interface(), nil
+		}
+		return nil, fmt.Errorf("%T has no field '%s'", v, name)
+	case reflect.String:
+		if name == "length" {
+			return valueLength(v)
+		}
+	case reflect.Slice, reflect.Array:
+		if name == "length" {
+			return valueLength(v)
+		}
+		i, err := strconv.Atoi(name)
+		if err != nil {
+			return nil, fmt.Errorf("can not access '%s' of %T", name, v)
+		}
+		if i < 0 || i >= rv.Len() {
+			return nil, fmt.Errorf("index %d is out of range (length %d)", i, rv.Len())
+		}
+		return rv.Index(i).Interface(), nil
+	}
+	return nil, fmt.Errorf("can not access '%s' of %T", name, v)
+}
+
+func structField(rv reflect.Value, name string) (reflect.Value, bool) {
+	rt := rv.Type()
+	for _, tag := range []string{"twowaysql", "db"} {
+		for i := 0; i < rt.NumField(); i++ {
+			f := rt.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			tagValue, _, _ := strings.Cut(f.Tag.Get(tag), ",")
+			if tagValue == name {
+				return rv.Field(i), true
+			}
+		}
+	}
+	if f, ok := rt.FieldByName(name); ok && f.IsExported() {
+		return rv.FieldByIndex(f.Index), true
+	}
+	return reflect.Value{}, false
+}